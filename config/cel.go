@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+)
+
+// celEnv 是整个进程共享的CEL环境，声明了When表达式可以引用的变量：
+// request（method/path/host/header）、response（status/header）、body（当前待替换的内容）
+var celEnv *cel.Env
+
+func init() {
+	env, err := cel.NewEnv(
+		cel.Variable("request", cel.DynType),
+		cel.Variable("response", cel.DynType),
+		cel.Variable("body", cel.StringType),
+	)
+	if err != nil {
+		// celEnv的变量声明是静态常量，只有在开发时改错才会失败，这里panic能在编译自检阶段就暴露问题
+		panic(fmt.Sprintf("初始化CEL环境失败: %v", err))
+	}
+	celEnv = env
+}
+
+// compileWhen 编译规则的When表达式为CEL程序，结果缓存在rule.compiledWhen上供Match复用
+func compileWhen(when string) (cel.Program, error) {
+	ast, issues := celEnv.Compile(when)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+
+	prg, err := celEnv.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("构建CEL程序失败: %v", err)
+	}
+	return prg, nil
+}
+
+// evalWhen 用proxy层提供的请求/响应元数据和当前body执行When表达式，返回布尔结果。
+// 表达式执行出错时按不匹配处理，避免一条写错的When表达式导致整个代理panic或放行所有流量。
+func evalWhen(prg cel.Program, ctx *EngineContext, body string) bool {
+	vars := map[string]interface{}{
+		"request":  celRequestVars(ctx),
+		"response": celResponseVars(ctx),
+		"body":     body,
+	}
+
+	out, _, err := prg.Eval(vars)
+	if err != nil {
+		return false
+	}
+
+	matched, ok := out.Value().(bool)
+	return ok && matched
+}
+
+// celRequestVars 把EngineContext中与请求相关的字段整理成CEL可以按request.xxx访问的map
+func celRequestVars(ctx *EngineContext) map[string]interface{} {
+	if ctx == nil || ctx.Direction == DirectionResponse {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"method": ctx.Method,
+		"path":   ctx.Path,
+		"host":   ctx.Host,
+		"header": flattenHeader(ctx.Header),
+	}
+}
+
+// celResponseVars 把EngineContext中与响应相关的字段整理成CEL可以按response.xxx访问的map
+func celResponseVars(ctx *EngineContext) map[string]interface{} {
+	if ctx == nil || ctx.Direction == DirectionRequest {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"status":       ctx.StatusCode,
+		"header":       flattenHeader(ctx.Header),
+		"content_type": ctx.ContentType,
+	}
+}
+
+// flattenHeader 把net/http风格的多值header转换为CEL表达式里常见的单值map（取第一个值），
+// 这样When表达式可以写 response.header["Content-Type"] == "application/json" 而不必处理切片
+func flattenHeader(header map[string][]string) map[string]interface{} {
+	flat := make(map[string]interface{}, len(header))
+	for k, v := range header {
+		if len(v) > 0 {
+			flat[k] = v[0]
+		} else {
+			flat[k] = ""
+		}
+	}
+	return flat
+}
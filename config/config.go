@@ -5,6 +5,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -17,12 +18,25 @@ type Config struct {
 	Logging  LoggingConfig  `yaml:"logging"`
 	Rules    RulesConfig    `yaml:"rules"`
 	Debug    DebugConfig    `yaml:"debug"`
+	Admin    AdminConfig    `yaml:"admin"`
+}
+
+// AdminConfig 管理接口配置（控制面），与代理数据面监听端口分离
+type AdminConfig struct {
+	Enabled bool   `yaml:"enabled" default:"false"`
+	Host    string `yaml:"host" default:"127.0.0.1"`
+	Port    int    `yaml:"port" default:"9090"`
+	Token   string `yaml:"token,omitempty"` // 非空时，管理接口要求Authorization: Bearer <token>
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
 	Host string `yaml:"host" default:"0.0.0.0"`
 	Port int    `yaml:"port" default:"8080"`
+
+	// MetricsPath非空时，在这个数据面监听端口上直接暴露Prometheus抓取端点（如"/metrics"），
+	// 与/admin/metrics（管理面端口，需要cfg.Admin.Enabled）互不影响；默认为空即不在数据面暴露
+	MetricsPath string `yaml:"metrics_path,omitempty"`
 }
 
 // TargetConfig 目标服务器配置（支持多服务器）
@@ -38,9 +52,42 @@ type TargetConfig struct {
 	
 	// 负载均衡配置
 	Strategy string `yaml:"strategy" default:"round_robin"`
-	
+
 	// 健康检查配置
 	HealthCheck HealthCheckConfig `yaml:"health_check"`
+
+	// 重试配置（仅多目标模式生效）
+	Retry RetryConfig `yaml:"retry"`
+
+	// 对冲请求配置
+	Hedge HedgeConfig `yaml:"hedge"`
+
+	// 流式转发配置：命中时跳过本模块默认的"解码-滑窗替换-重新编码到内存缓冲"路径，
+	// 改为边读边写、不在内存里攒完整响应体，适合SSE、chunked大文件下载等场景
+	Stream           bool     `yaml:"stream" default:"false"`
+	StreamPaths      []string `yaml:"stream_paths,omitempty"`       // 路径前缀白名单，命中其一才走流式路径；为空表示Stream为true时对所有路径生效
+	StreamWindowSize int      `yaml:"stream_window_size,omitempty"` // 滑动窗口字节数，0表示使用StreamEngine的自适应默认值
+
+	// EnableUpgrades为true时，代理为该目标启用WebSocket连接劫持转发（handleUpgrade）；
+	// 默认为false，升级类请求按普通请求转发（Connection/Upgrade头会被剥离，握手会失败）。
+	// HTTP/2（ForceAttemptHTTP2）对https目标始终按ALPN协商自动生效，不受此开关影响
+	EnableUpgrades bool `yaml:"enable_upgrades" default:"false"`
+}
+
+// ShouldStream 判断给定请求路径是否应该走流式转发路径（不缓冲完整响应体）
+func (t *TargetConfig) ShouldStream(path string) bool {
+	if !t.Stream {
+		return false
+	}
+	if len(t.StreamPaths) == 0 {
+		return true
+	}
+	for _, prefix := range t.StreamPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
 }
 
 // HealthCheckConfig 健康检查配置
@@ -49,12 +96,54 @@ type HealthCheckConfig struct {
 	Interval time.Duration `yaml:"interval" default:"30s"`
 	Path    string        `yaml:"path" default:"/health"`
 	Timeout time.Duration `yaml:"timeout" default:"5s"`
+
+	// FailureThreshold次连续探测失败后才把目标标记为不健康；此后探测间隔按interval*2^n指数退避，直到MaxBackoff封顶
+	FailureThreshold int           `yaml:"failure_threshold" default:"3"`
+	MaxBackoff       time.Duration `yaml:"max_backoff" default:"5m"`
+
+	// HealthyThreshold次连续探测成功后，已被标记为不健康的目标才会恢复为健康（Kubernetes readiness探针语义），
+	// 避免刚恢复、尚不稳定的目标被立刻重新打满流量；默认为1表示探测成功一次即恢复，与旧行为保持兼容
+	HealthyThreshold int `yaml:"healthy_threshold" default:"1"`
+}
+
+// RetryConfig 转发重试配置：对连接错误/5xx/超时的请求在LoadBalancer的其他目标间重试，
+// 借鉴client-go URLBackoff模式，按指数增长+随机抖动计算重试间隔
+type RetryConfig struct {
+	MaxRetries         int           `yaml:"max_retries"`                          // 除首次尝试外最多再重试几次；0表示沿用旧行为，在目标池范围内尽量多尝试
+	BackoffBase        time.Duration `yaml:"backoff_base" default:"100ms"`         // 重试退避基准时长
+	BackoffCap         time.Duration `yaml:"backoff_cap" default:"2s"`             // 重试退避时长上限
+	AllowNonIdempotent bool          `yaml:"allow_non_idempotent" default:"false"` // 为true时POST/PATCH等非幂等方法也参与重试/对冲，否则失败直接返回，避免重复副作用
+}
+
+// HedgeConfig 对冲请求配置：主请求超过Delay仍未返回时，向另一个目标发起第二次尝试，
+// 取先完成的一个，另一个通过context取消
+type HedgeConfig struct {
+	Enabled bool          `yaml:"enabled" default:"false"`
+	Delay   time.Duration `yaml:"delay" default:"200ms"`
 }
 
 // LoggingConfig 日志配置
 type LoggingConfig struct {
-	Level string `yaml:"level" default:"info"`
-	File  string `yaml:"file" default:"logs/proxy.log"`
+	Level  string `yaml:"level" default:"info"`  // trace | debug | info | warn | error
+	File   string `yaml:"file" default:"logs/proxy.log"`
+	Format string `yaml:"format" default:"text"` // text（带颜色，面向终端）| json（结构化单行，面向日志采集管道）
+
+	// 文件滚动：MaxSizeMB达到后触发滚动，MaxAgeDays/MaxBackups控制旧文件保留策略；
+	// 三者均为0表示不滚动（兼容旧行为，只追加写入File）
+	MaxSizeMB  int `yaml:"max_size_mb,omitempty"`
+	MaxAgeDays int `yaml:"max_age_days,omitempty"`
+	MaxBackups int `yaml:"max_backups,omitempty"`
+
+	Syslog   SyslogConfig `yaml:"syslog,omitempty"`
+	HTTPSink string       `yaml:"http_sink,omitempty"` // 非空时把结构化日志行异步POST到该URL，用于接入集中式日志/观测平台
+}
+
+// SyslogConfig 转发到syslog的配置；Network为空时写本地syslog，否则通过Network/Address远程投递
+type SyslogConfig struct {
+	Enabled bool   `yaml:"enabled" default:"false"`
+	Network string `yaml:"network,omitempty"` // tcp | udp，空表示本地syslog
+	Address string `yaml:"address,omitempty"`
+	Tag     string `yaml:"tag,omitempty"`
 }
 
 // RulesConfig 规则配置
@@ -134,12 +223,40 @@ func setDefaults(config *Config) {
 	if config.Target.HealthCheck.Timeout == 0 {
 		config.Target.HealthCheck.Timeout = 5 * time.Second
 	}
+	if config.Target.HealthCheck.FailureThreshold == 0 {
+		config.Target.HealthCheck.FailureThreshold = 3
+	}
+	if config.Target.HealthCheck.MaxBackoff == 0 {
+		config.Target.HealthCheck.MaxBackoff = 5 * time.Minute
+	}
+	if config.Target.HealthCheck.HealthyThreshold == 0 {
+		config.Target.HealthCheck.HealthyThreshold = 1
+	}
+	if config.Target.Retry.BackoffBase == 0 {
+		config.Target.Retry.BackoffBase = 100 * time.Millisecond
+	}
+	if config.Target.Retry.BackoffCap == 0 {
+		config.Target.Retry.BackoffCap = 2 * time.Second
+	}
+	if config.Target.Hedge.Enabled && config.Target.Hedge.Delay == 0 {
+		config.Target.Hedge.Delay = 200 * time.Millisecond
+	}
 	if config.Logging.Level == "" {
 		config.Logging.Level = "info"
 	}
 	if config.Logging.File == "" {
 		config.Logging.File = "logs/proxy.log"
 	}
+	if config.Logging.Format == "" {
+		config.Logging.Format = "text"
+	}
+	// 管理接口默认值
+	if config.Admin.Host == "" {
+		config.Admin.Host = "127.0.0.1"
+	}
+	if config.Admin.Port == 0 {
+		config.Admin.Port = 9090
+	}
 }
 
 // validate 验证配置
@@ -182,6 +299,11 @@ func (c *Config) GetAddress() string {
 	return fmt.Sprintf("%s:%d", c.Server.Host, c.Server.Port)
 }
 
+// GetAdminAddress 获取管理接口监听地址
+func (c *Config) GetAdminAddress() string {
+	return fmt.Sprintf("%s:%d", c.Admin.Host, c.Admin.Port)
+}
+
 // IsDebugEnabled 检查是否启用调试模式
 func (c *Config) IsDebugEnabled() bool {
 	return c.Debug.Enabled
@@ -240,10 +362,14 @@ func validateTargetConfig(target *TargetConfig) error {
 	
 	// 验证负载均衡策略
 	validStrategies := map[string]bool{
-		"round_robin": true,
-		// 为未来扩展预留
+		"round_robin":          true,
+		"weighted":             true,
+		"least_conn":           true,
+		"ip_hash":              true,
+		"p2c_ewma":             true,
+		// 兼容旧配置中使用过的别名
 		"weighted_round_robin": true,
-		"least_connections": true,
+		"least_connections":    true,
 	}
 	
 	if !validStrategies[target.Strategy] {
@@ -272,10 +398,16 @@ func (t *TargetConfig) IsMultiTarget() bool {
 	return len(t.URLs) > 1
 }
 
-// GetStrategy 获取负载均衡策略
+// GetStrategy 获取负载均衡策略，并把旧别名归一化为当前LoadBalancer识别的策略名
 func (t *TargetConfig) GetStrategy() string {
-	if t.Strategy == "" {
+	switch t.Strategy {
+	case "", "round_robin":
 		return "round_robin"
+	case "weighted_round_robin":
+		return "weighted"
+	case "least_connections":
+		return "least_conn"
+	default:
+		return t.Strategy
 	}
-	return t.Strategy
 }
\ No newline at end of file
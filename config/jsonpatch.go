@@ -0,0 +1,412 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPatchOp 对应RFC 6902 JSON Patch中的一条操作
+type jsonPatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+	From  string      `json:"from,omitempty"`
+}
+
+// validateJSONPatchOp 在加载阶段校验单条json_patch操作，避免规则上线后才发现op/path写错
+func validateJSONPatchOp(op jsonPatchOp) error {
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Path == "" {
+			return fmt.Errorf("op=%s缺少path", op.Op)
+		}
+	case "remove":
+		if op.Path == "" {
+			return fmt.Errorf("op=remove缺少path")
+		}
+	case "move", "copy":
+		if op.Path == "" || op.From == "" {
+			return fmt.Errorf("op=%s需要同时指定path和from", op.Op)
+		}
+	default:
+		return fmt.Errorf("不支持的op '%s'，支持: add, remove, replace, move, copy, test", op.Op)
+	}
+	return nil
+}
+
+// ApplyJSONMergeRules 在同一次Unmarshal/Marshal中依次应用一批json_merge_patch/json_patch规则，
+// 取代逐条Rule.Apply各自反复解码/编码响应体的做法。规则按传入顺序应用，FieldManager相同或为空
+// 视为同一持有者；当后续规则要接管别的管理器已持有的顶层字段时，必须设置ForceConflicts，否则报错
+// 跳过该规则（类似kubectl apply --force-conflicts）
+func ApplyJSONMergeRules(content string, rules []Rule) (string, error) {
+	var tree interface{}
+	if err := json.Unmarshal([]byte(content), &tree); err != nil {
+		return content, fmt.Errorf("待合并内容不是合法JSON: %v", err)
+	}
+
+	owners := make(map[string]string)
+
+	for _, rule := range rules {
+		manager := rule.FieldManager
+		if manager == "" {
+			manager = rule.Name
+		}
+
+		keys := touchedTopLevelKeys(rule)
+		conflict := ""
+		for _, key := range keys {
+			if owner, ok := owners[key]; ok && owner != manager {
+				conflict = key
+				break
+			}
+		}
+		if conflict != "" && !rule.ForceConflicts {
+			return content, fmt.Errorf("字段 %q 已由管理器 %q 持有，规则 %s（管理器 %s）未设置force_conflicts，拒绝接管", conflict, owners[conflict], rule.Name, manager)
+		}
+
+		var err error
+		switch rule.Action {
+		case ActionJsonMerge:
+			var patch interface{}
+			if err = json.Unmarshal([]byte(rule.Value), &patch); err == nil {
+				tree = mergePatch(tree, patch)
+			}
+		case ActionJsonStrategicPatch:
+			var ops []jsonPatchOp
+			if err = json.Unmarshal([]byte(rule.Value), &ops); err == nil {
+				tree, err = applyJSONPatchOps(tree, ops)
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return content, fmt.Errorf("规则 %s 应用JSON补丁失败: %v", rule.Name, err)
+		}
+
+		for _, key := range keys {
+			owners[key] = manager
+		}
+	}
+
+	out, err := json.MarshalIndent(tree, "", "  ")
+	if err != nil {
+		return content, fmt.Errorf("重新编码JSON失败: %v", err)
+	}
+	return string(out), nil
+}
+
+// touchedTopLevelKeys 返回一条规则实际会改动的顶层字段名，供FieldManager做冲突检测。
+// 只追踪顶层字段（而非kubectl式的逐叶子字段管理），这对绝大多数响应体改写场景已经够用
+func touchedTopLevelKeys(rule Rule) []string {
+	switch rule.Action {
+	case ActionJsonMerge:
+		var patch map[string]interface{}
+		if err := json.Unmarshal([]byte(rule.Value), &patch); err != nil {
+			return nil
+		}
+		keys := make([]string, 0, len(patch))
+		for k := range patch {
+			keys = append(keys, k)
+		}
+		return keys
+	case ActionJsonStrategicPatch:
+		var ops []jsonPatchOp
+		if err := json.Unmarshal([]byte(rule.Value), &ops); err != nil {
+			return nil
+		}
+		seen := make(map[string]bool, len(ops))
+		var keys []string
+		for _, op := range ops {
+			if key := topLevelKeyOfPointer(op.Path); key != "" && !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	default:
+		return nil
+	}
+}
+
+// topLevelKeyOfPointer 提取JSON Pointer（如"/data/items/0"）的第一段
+func topLevelKeyOfPointer(pointer string) string {
+	segments := splitJSONPointer(pointer)
+	if len(segments) == 0 {
+		return ""
+	}
+	return segments[0]
+}
+
+// mergePatch 实现RFC 7396 JSON Merge Patch：patch中值为null的key从tree中删除，
+// 值为object的key递归合并，其余情况直接覆盖
+func mergePatch(tree interface{}, patch interface{}) interface{} {
+	patchMap, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	treeMap, ok := tree.(map[string]interface{})
+	if !ok {
+		treeMap = make(map[string]interface{})
+	} else {
+		merged := make(map[string]interface{}, len(treeMap))
+		for k, v := range treeMap {
+			merged[k] = v
+		}
+		treeMap = merged
+	}
+
+	for k, v := range patchMap {
+		if v == nil {
+			delete(treeMap, k)
+			continue
+		}
+		treeMap[k] = mergePatch(treeMap[k], v)
+	}
+
+	return treeMap
+}
+
+// applyJSONPatchOps 实现RFC 6902 JSON Patch中add/remove/replace/move/copy/test这几类常见操作
+func applyJSONPatchOps(tree interface{}, ops []jsonPatchOp) (interface{}, error) {
+	for _, op := range ops {
+		var err error
+		switch op.Op {
+		case "add":
+			tree, err = setJSONPointer(tree, op.Path, op.Value, true)
+		case "replace":
+			tree, err = setJSONPointer(tree, op.Path, op.Value, false)
+		case "remove":
+			tree, err = removeJSONPointer(tree, op.Path)
+		case "test":
+			var current interface{}
+			current, err = getJSONPointer(tree, op.Path)
+			if err == nil && !jsonEqual(current, op.Value) {
+				err = fmt.Errorf("test操作失败: %s处的值与期望不符", op.Path)
+			}
+		case "move":
+			var val interface{}
+			val, err = getJSONPointer(tree, op.From)
+			if err == nil {
+				tree, err = removeJSONPointer(tree, op.From)
+			}
+			if err == nil {
+				tree, err = setJSONPointer(tree, op.Path, val, true)
+			}
+		case "copy":
+			var val interface{}
+			val, err = getJSONPointer(tree, op.From)
+			if err == nil {
+				tree, err = setJSONPointer(tree, op.Path, val, true)
+			}
+		default:
+			err = fmt.Errorf("不支持的op '%s'", op.Op)
+		}
+		if err != nil {
+			return tree, err
+		}
+	}
+	return tree, nil
+}
+
+// splitJSONPointer 把RFC 6901 JSON Pointer拆成不含转义的段，"" 或 "/" 表示根
+func splitJSONPointer(pointer string) []string {
+	if pointer == "" || pointer == "/" {
+		return nil
+	}
+	raw := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	segments := make([]string, len(raw))
+	for i, seg := range raw {
+		seg = strings.ReplaceAll(seg, "~1", "/")
+		seg = strings.ReplaceAll(seg, "~0", "~")
+		segments[i] = seg
+	}
+	return segments
+}
+
+// getJSONPointer 按JSON Pointer读取节点
+func getJSONPointer(node interface{}, pointer string) (interface{}, error) {
+	segments := splitJSONPointer(pointer)
+	current := node
+	for _, seg := range segments {
+		switch n := current.(type) {
+		case map[string]interface{}:
+			v, ok := n[seg]
+			if !ok {
+				return nil, fmt.Errorf("path %s不存在", pointer)
+			}
+			current = v
+		case []interface{}:
+			idx, err := strconv.Atoi(seg)
+			if err != nil || idx < 0 || idx >= len(n) {
+				return nil, fmt.Errorf("path %s下标越界", pointer)
+			}
+			current = n[idx]
+		default:
+			return nil, fmt.Errorf("path %s无法继续深入", pointer)
+		}
+	}
+	return current, nil
+}
+
+// setJSONPointer 按JSON Pointer写入/新增节点，返回新的根节点（不可变风格，便于上层共享tree时追踪变更）
+func setJSONPointer(node interface{}, pointer string, value interface{}, insert bool) (interface{}, error) {
+	segments := splitJSONPointer(pointer)
+	if len(segments) == 0 {
+		return value, nil
+	}
+	return setJSONPointerRec(node, segments, value, insert, pointer)
+}
+
+func setJSONPointerRec(node interface{}, segments []string, value interface{}, insert bool, pointer string) (interface{}, error) {
+	seg := segments[0]
+	rest := segments[1:]
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(n)+1)
+		for k, v := range n {
+			result[k] = v
+		}
+		if len(rest) == 0 {
+			result[seg] = value
+			return result, nil
+		}
+		child, ok := result[seg]
+		if !ok {
+			if !insert {
+				return node, fmt.Errorf("path %s不存在", pointer)
+			}
+			child = map[string]interface{}{}
+		}
+		newChild, err := setJSONPointerRec(child, rest, value, insert, pointer)
+		if err != nil {
+			return node, err
+		}
+		result[seg] = newChild
+		return result, nil
+
+	case []interface{}:
+		result := make([]interface{}, len(n))
+		copy(result, n)
+		if len(rest) == 0 {
+			if seg == "-" {
+				if !insert {
+					return node, fmt.Errorf("path %s的\"-\"只能用于add", pointer)
+				}
+				return append(result, value), nil
+			}
+			idx, err := strconv.Atoi(seg)
+			if err != nil || idx < 0 || idx > len(result) {
+				return node, fmt.Errorf("path %s下标越界", pointer)
+			}
+			if insert {
+				result = append(result, nil)
+				copy(result[idx+1:], result[idx:])
+				result[idx] = value
+				return result, nil
+			}
+			if idx == len(result) {
+				return node, fmt.Errorf("path %s下标越界", pointer)
+			}
+			result[idx] = value
+			return result, nil
+		}
+		idx, err := strconv.Atoi(seg)
+		if err != nil || idx < 0 || idx >= len(result) {
+			return node, fmt.Errorf("path %s下标越界", pointer)
+		}
+		newChild, err := setJSONPointerRec(result[idx], rest, value, insert, pointer)
+		if err != nil {
+			return node, err
+		}
+		result[idx] = newChild
+		return result, nil
+
+	default:
+		if insert && len(rest) == 0 {
+			return map[string]interface{}{seg: value}, nil
+		}
+		return node, fmt.Errorf("path %s无法继续深入", pointer)
+	}
+}
+
+// removeJSONPointer 按JSON Pointer删除节点，返回新的根节点
+func removeJSONPointer(node interface{}, pointer string) (interface{}, error) {
+	segments := splitJSONPointer(pointer)
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("不能remove根节点")
+	}
+	return removeJSONPointerRec(node, segments, pointer)
+}
+
+func removeJSONPointerRec(node interface{}, segments []string, pointer string) (interface{}, error) {
+	seg := segments[0]
+	rest := segments[1:]
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(rest) == 0 {
+			if _, ok := n[seg]; !ok {
+				return node, fmt.Errorf("path %s不存在", pointer)
+			}
+			result := make(map[string]interface{}, len(n)-1)
+			for k, v := range n {
+				if k != seg {
+					result[k] = v
+				}
+			}
+			return result, nil
+		}
+		child, ok := n[seg]
+		if !ok {
+			return node, fmt.Errorf("path %s不存在", pointer)
+		}
+		newChild, err := removeJSONPointerRec(child, rest, pointer)
+		if err != nil {
+			return node, err
+		}
+		result := make(map[string]interface{}, len(n))
+		for k, v := range n {
+			result[k] = v
+		}
+		result[seg] = newChild
+		return result, nil
+
+	case []interface{}:
+		idx, err := strconv.Atoi(seg)
+		if err != nil || idx < 0 || idx >= len(n) {
+			return node, fmt.Errorf("path %s下标越界", pointer)
+		}
+		if len(rest) == 0 {
+			result := make([]interface{}, 0, len(n)-1)
+			result = append(result, n[:idx]...)
+			result = append(result, n[idx+1:]...)
+			return result, nil
+		}
+		result := make([]interface{}, len(n))
+		copy(result, n)
+		newChild, err := removeJSONPointerRec(result[idx], rest, pointer)
+		if err != nil {
+			return node, err
+		}
+		result[idx] = newChild
+		return result, nil
+
+	default:
+		return node, fmt.Errorf("path %s无法继续深入", pointer)
+	}
+}
+
+// jsonEqual 用于test操作，通过重新编码为JSON字符串比较两个decode后的值是否一致，
+// 避免手写逐类型的深度比较
+func jsonEqual(a, b interface{}) bool {
+	aBytes, errA := json.Marshal(a)
+	bBytes, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return string(aBytes) == string(bBytes)
+}
@@ -3,13 +3,53 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 
+	"github.com/google/cel-go/cel"
 	"gopkg.in/yaml.v3"
 )
 
+// captureGroupRefPattern 匹配替换值中的捕获组引用，对应regexp.Expand支持的$name/${name}语法
+var captureGroupRefPattern = regexp.MustCompile(`\$(?:\{(\w+)\}|(\w+))`)
+
+// validateCaptureGroupRefs 检查替换值中引用的捕获组（数字如$1，或命名如${name}）是否存在于正则表达式中，
+// 避免规则上线后才发现替换值写错了组号，导致替换结果里残留字面量"$1"
+func validateCaptureGroupRefs(re *regexp.Regexp, value string) error {
+	maxGroups := re.NumSubexp()
+	names := re.SubexpNames()
+	namedGroups := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name != "" {
+			namedGroups[name] = true
+		}
+	}
+
+	for _, match := range captureGroupRefPattern.FindAllStringSubmatch(value, -1) {
+		ref := match[1]
+		if ref == "" {
+			ref = match[2]
+		}
+
+		if n, err := strconv.Atoi(ref); err == nil {
+			if n < 1 || n > maxGroups {
+				return fmt.Errorf("引用了不存在的捕获组 $%s（正则共有%d个捕获组）", ref, maxGroups)
+			}
+			continue
+		}
+
+		if !namedGroups[ref] {
+			return fmt.Errorf("引用了不存在的命名捕获组 $%s", ref)
+		}
+	}
+
+	return nil
+}
+
 // RuleMode 匹配模式枚举
 type RuleMode string
 
@@ -24,9 +64,20 @@ const (
 type RuleAction string
 
 const (
-	ActionReplace         RuleAction = "replace"           // 替换
-	ActionDelete          RuleAction = "delete"            // 删除
-	ActionDeleteJsonField RuleAction = "delete_json_field" // 删除JSON字段
+	ActionReplace             RuleAction = "replace"              // 替换
+	ActionDelete              RuleAction = "delete"               // 删除
+	ActionDeleteJsonField     RuleAction = "delete_json_field"     // 删除JSON字段
+	ActionJsonMerge           RuleAction = "json_merge_patch"      // RFC 7396 JSON Merge Patch，Value为合并文档
+	ActionJsonStrategicPatch  RuleAction = "json_patch"            // RFC 6902 JSON Patch，Value为操作数组
+)
+
+// RuleDirection 规则生效方向
+type RuleDirection string
+
+const (
+	DirectionRequest  RuleDirection = "request"  // 仅作用于请求体
+	DirectionResponse RuleDirection = "response" // 仅作用于响应体
+	DirectionBoth     RuleDirection = "both"      // 请求/响应都生效（默认）
 )
 
 // Rule 替换规则结构
@@ -37,6 +88,29 @@ type Rule struct {
 	Pattern string     `yaml:"pattern"`  // 匹配模式
 	Action  RuleAction `yaml:"action"`   // 动作类型
 	Value   string     `yaml:"value"`    // 替换值（仅用于替换动作）
+
+	// 以下为作用范围限定条件，全部为空表示对所有请求/响应生效
+	Methods        []string          `yaml:"methods,omitempty"`         // 限定HTTP方法，如 [GET, POST]
+	PathRegex      string            `yaml:"path_regex,omitempty"`      // 限定请求路径的正则
+	HostGlob       string            `yaml:"host_glob,omitempty"`       // 限定Host的glob模式，如 *.example.com
+	HeaderMatchers map[string]string `yaml:"header_matchers,omitempty"` // 限定必须存在且匹配的请求/响应头
+	ContentTypes   []string          `yaml:"content_types,omitempty"`   // 限定Content-Type前缀，如 application/json
+	Direction      RuleDirection     `yaml:"direction,omitempty"`       // request | response | both，默认both
+
+	When string `yaml:"when,omitempty"` // 可选CEL表达式，为request/response/body附加自定义匹配条件，如 request.method == "POST"
+	Path string `yaml:"path,omitempty"` // 可选JSONPath风格路径（如 data.items.*.message），限定替换动作只作用于该子树，为空时作用于整个content
+
+	// 以下两个字段仅用于action=json_merge_patch/json_patch，借鉴kubectl Server-Side-Apply的字段管理模型
+	FieldManager   string `yaml:"field_manager,omitempty"`   // 声明本规则修改字段的"持有者"，为空时取Name
+	ForceConflicts bool   `yaml:"force_conflicts,omitempty"` // 为true时允许接管其他管理器已持有的字段，否则冲突时本规则直接报错跳过
+
+	compiledPathRegex *regexp.Regexp // 由CompileScope在加载阶段编译，避免每次请求重新编译
+	compiledRegex     *regexp.Regexp // mode=regex时，Pattern在加载阶段编译后的缓存，避免Match/Apply每次都regexp.Compile
+	compiledWhen      cel.Program    // When非空时，由validateRule在加载阶段编译缓存，避免Match每次都重新编译CEL表达式
+
+	// SourceFile是规则加载自的文件路径，由LoadRulesFromPaths填充，不参与YAML/JSON序列化。
+	// admin包用它定位某条规则应该被持久化回写到RulesConfig.Files中的哪一个文件
+	SourceFile string `yaml:"-" json:"-"`
 }
 
 // RulesConfig 规则配置文件结构
@@ -102,6 +176,11 @@ func LoadRulesFromPaths(rulesPaths []string) ([]Rule, error) {
 		if err != nil {
 			return nil, fmt.Errorf("加载规则文件失败 %s: %v", rulesPath, err)
 		}
+		// 标记规则的归属文件（即便规则是从easy.delete.contains引用的外部yaml展开的），
+		// 供admin接口在运行时增删改规则后知道应该把变更写回RulesConfig.Files中的哪一个文件
+		for i := range rules {
+			rules[i].SourceFile = rulesPath
+		}
 		allRules = append(allRules, rules...)
 	}
 
@@ -180,6 +259,35 @@ func loadSingleRulesFile(rulesPath string) ([]Rule, error) {
 	return allRules, nil
 }
 
+// ReferencedExternalPaths 扫描规则文件中通过easy.delete.contains引用的外部YAML文件路径，
+// 供Watcher在监听主规则文件之外，也一并监听这些被引用文件的变化
+func ReferencedExternalPaths(rulesPaths []string) []string {
+	var external []string
+
+	for _, rulesPath := range rulesPaths {
+		data, err := os.ReadFile(rulesPath)
+		if err != nil {
+			continue
+		}
+
+		var rulesFile RulesFile
+		if err := yaml.Unmarshal(data, &rulesFile); err != nil {
+			continue
+		}
+		if rulesFile.Easy == nil || rulesFile.Easy.Delete == nil {
+			continue
+		}
+
+		for _, path := range rulesFile.Easy.Delete.Contains {
+			if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+				external = append(external, path)
+			}
+		}
+	}
+
+	return external
+}
+
 // hasEasyRules 检查是否包含简单规则
 func hasEasyRules(easyRules *EasyRules) bool {
 	if easyRules == nil {
@@ -233,12 +341,49 @@ func hasEasyRules(easyRules *EasyRules) bool {
 	return false
 }
 
+// RuleID 返回规则的短哈希标识，供admin API以稳定的方式引用规则，而不是容易随重载/增删
+// 漂移的数组下标。基于规则名称做fnv32a哈希再转成36进制短字符串
+func RuleID(rule Rule) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(rule.Name))
+	return strconv.FormatUint(uint64(h.Sum32()), 36)
+}
+
+// SaveRulesToFile 把一组规则以标准RulesFile格式写回YAML文件，供admin API持久化运行时的增删改，
+// 使其在下次进程重启/规则重载后依然生效
+func SaveRulesToFile(path string, rules []Rule) error {
+	data, err := yaml.Marshal(RulesFile{Rules: rules})
+	if err != nil {
+		return fmt.Errorf("序列化规则失败: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("写入规则文件失败 %s: %v", path, err)
+	}
+	return nil
+}
+
+// ValidateRules 验证规则集合（供外部包在运行时校验用户提交的规则，如管理API）
+func ValidateRules(rules []Rule) error {
+	return validateRules(rules)
+}
+
+// ValidateRule 验证单条规则
+func ValidateRule(rule *Rule) error {
+	return validateRule(rule, 0)
+}
+
 // validateRules 验证规则
 func validateRules(rules []Rule) error {
-	for i, rule := range rules {
-		if err := validateRule(&rule, i); err != nil {
+	seenNames := make(map[string]int, len(rules))
+	for i := range rules {
+		rule := rules[i]
+		if err := validateRule(&rules[i], i); err != nil {
 			return err
 		}
+		if prevIndex, exists := seenNames[rule.Name]; exists {
+			return fmt.Errorf("规则 #%d (%s): 规则名称与规则 #%d 重复", i+1, rule.Name, prevIndex+1)
+		}
+		seenNames[rule.Name] = i
 	}
 	return nil
 }
@@ -249,68 +394,244 @@ func validateRule(rule *Rule, index int) error {
 		return fmt.Errorf("规则 #%d: 规则名称不能为空", index+1)
 	}
 
-	if rule.Mode == "" {
-		return fmt.Errorf("规则 #%d (%s): 匹配模式不能为空", index+1, rule.Name)
-	}
-
-	// 验证匹配模式
-	validModes := map[RuleMode]bool{
-		ModePrefix:   true,
-		ModeSuffix:   true,
-		ModeContains: true,
-		ModeRegex:    true,
-	}
-	if !validModes[rule.Mode] {
-		return fmt.Errorf("规则 #%d (%s): 无效的匹配模式 '%s'，支持的模式: prefix, suffix, contains, regex", 
-			index+1, rule.Name, rule.Mode)
-	}
-
-	if rule.Pattern == "" {
-		return fmt.Errorf("规则 #%d (%s): 匹配内容不能为空", index+1, rule.Name)
-	}
-
 	if rule.Action == "" {
 		return fmt.Errorf("规则 #%d (%s): 动作类型不能为空", index+1, rule.Name)
 	}
 
 	// 验证动作类型
 	validActions := map[RuleAction]bool{
-		ActionReplace:         true,
-		ActionDelete:          true,
-		ActionDeleteJsonField: true,
+		ActionReplace:            true,
+		ActionDelete:             true,
+		ActionDeleteJsonField:    true,
+		ActionJsonMerge:          true,
+		ActionJsonStrategicPatch: true,
 	}
 	if !validActions[rule.Action] {
-		return fmt.Errorf("规则 #%d (%s): 无效的动作类型 '%s'，支持的动作: replace, delete, delete_json_field", 
+		return fmt.Errorf("规则 #%d (%s): 无效的动作类型 '%s'，支持的动作: replace, delete, delete_json_field, json_merge_patch, json_patch",
 			index+1, rule.Name, rule.Action)
 	}
 
+	// json_merge_patch/json_patch不走Mode/Pattern字面量或正则匹配，而是把Value整体作为JSON补丁文档应用
+	isJSONPatchAction := rule.Action == ActionJsonMerge || rule.Action == ActionJsonStrategicPatch
+
+	if !isJSONPatchAction {
+		if rule.Mode == "" {
+			return fmt.Errorf("规则 #%d (%s): 匹配模式不能为空", index+1, rule.Name)
+		}
+
+		// 验证匹配模式
+		validModes := map[RuleMode]bool{
+			ModePrefix:   true,
+			ModeSuffix:   true,
+			ModeContains: true,
+			ModeRegex:    true,
+		}
+		if !validModes[rule.Mode] {
+			return fmt.Errorf("规则 #%d (%s): 无效的匹配模式 '%s'，支持的模式: prefix, suffix, contains, regex",
+				index+1, rule.Name, rule.Mode)
+		}
+
+		if rule.Pattern == "" {
+			return fmt.Errorf("规则 #%d (%s): 匹配内容不能为空", index+1, rule.Name)
+		}
+	}
+
 	// 如果是替换动作，替换值不能为空
 	if rule.Action == ActionReplace && rule.Value == "" {
 		return fmt.Errorf("规则 #%d (%s): 替换动作的替换值不能为空", index+1, rule.Name)
 	}
 
-	// 如果是正则模式，验证正则表达式
+	// json_merge_patch的Value必须是合法JSON文档；json_patch的Value必须是合法的JSON Patch操作数组
+	if rule.Action == ActionJsonMerge {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(rule.Value), &doc); err != nil {
+			return fmt.Errorf("规则 #%d (%s): json_merge_patch的value不是合法JSON: %v", index+1, rule.Name, err)
+		}
+	}
+	if rule.Action == ActionJsonStrategicPatch {
+		var ops []jsonPatchOp
+		if err := json.Unmarshal([]byte(rule.Value), &ops); err != nil {
+			return fmt.Errorf("规则 #%d (%s): json_patch的value不是合法的JSON Patch操作数组: %v", index+1, rule.Name, err)
+		}
+		for i, op := range ops {
+			if err := validateJSONPatchOp(op); err != nil {
+				return fmt.Errorf("规则 #%d (%s): json_patch操作#%d无效: %v", index+1, rule.Name, i+1, err)
+			}
+		}
+	}
+
+	// 如果是正则模式，验证正则表达式，缓存编译结果供Match/Apply复用，并检查替换值中引用的捕获组是否存在
 	if rule.Mode == ModeRegex {
-		if _, err := regexp.Compile(rule.Pattern); err != nil {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
 			return fmt.Errorf("规则 #%d (%s): 正则表达式无效: %v", index+1, rule.Name, err)
 		}
+		rule.compiledRegex = re
+		if rule.Action == ActionReplace {
+			if err := validateCaptureGroupRefs(re, rule.Value); err != nil {
+				return fmt.Errorf("规则 #%d (%s): 替换值中的捕获组引用无效: %v", index+1, rule.Name, err)
+			}
+		}
+	}
+
+	if rule.Direction != "" {
+		validDirections := map[RuleDirection]bool{DirectionRequest: true, DirectionResponse: true, DirectionBoth: true}
+		if !validDirections[rule.Direction] {
+			return fmt.Errorf("规则 #%d (%s): 无效的生效方向 '%s'，支持的方向: request, response, both", index+1, rule.Name, rule.Direction)
+		}
+	}
+
+	// 作用范围条件在加载阶段就编译/校验一次，避免无效的path_regex/host_glob拖到请求时才报错
+	if err := rule.CompileScope(); err != nil {
+		return fmt.Errorf("规则 #%d (%s): %v", index+1, rule.Name, err)
+	}
+
+	// When表达式同样在加载阶段编译一次并缓存，避免无效的CEL表达式拖到请求时才报错
+	if rule.When != "" {
+		prg, err := compileWhen(rule.When)
+		if err != nil {
+			return fmt.Errorf("规则 #%d (%s): when表达式无效: %v", index+1, rule.Name, err)
+		}
+		rule.compiledWhen = prg
 	}
 
 	return nil
 }
 
-// Match 检查规则是否匹配内容
-func (r *Rule) Match(content string) bool {
+// CompileScope 编译/校验规则的作用范围条件（path_regex正则、host_glob语法），
+// 结果缓存在规则上供MatchScope复用，避免每次请求都重新编译正则
+func (r *Rule) CompileScope() error {
+	if r.PathRegex != "" {
+		re, err := regexp.Compile(r.PathRegex)
+		if err != nil {
+			return fmt.Errorf("path_regex无效: %v", err)
+		}
+		r.compiledPathRegex = re
+	}
+
+	if r.HostGlob != "" {
+		if _, err := filepath.Match(r.HostGlob, ""); err != nil {
+			return fmt.Errorf("host_glob无效: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// EngineContext 描述一次请求/响应的元数据，供规则的作用范围条件匹配（MatchScope）
+// 和When CEL表达式（Rule.Match）共同使用
+type EngineContext struct {
+	Method      string
+	Path        string
+	Host        string
+	Header      map[string][]string
+	ContentType string
+	Direction   RuleDirection // 当前处理的是request还是response
+	RequestID   string        // 用于结构化日志/指标关联同一次请求的request/response两个方向
+	StatusCode  int           // 仅Direction=response时有意义，供When表达式访问response.status
+}
+
+// MatchScope 检查规则的作用范围条件是否适用于给定的请求/响应上下文，
+// 在Match/Apply之前调用，避免把JSON规则误用到HTML body或无关路由上
+func (r *Rule) MatchScope(ctx *EngineContext) bool {
+	if ctx == nil {
+		return true
+	}
+
+	if r.Direction != "" && r.Direction != DirectionBoth && r.Direction != ctx.Direction {
+		return false
+	}
+
+	if len(r.Methods) > 0 && !containsFold(r.Methods, ctx.Method) {
+		return false
+	}
+
+	if r.compiledPathRegex != nil && !r.compiledPathRegex.MatchString(ctx.Path) {
+		return false
+	}
+
+	if r.HostGlob != "" {
+		matched, err := filepath.Match(r.HostGlob, ctx.Host)
+		if err != nil || !matched {
+			return false
+		}
+	}
+
+	if len(r.ContentTypes) > 0 {
+		matched := false
+		for _, ct := range r.ContentTypes {
+			if strings.HasPrefix(strings.ToLower(ctx.ContentType), strings.ToLower(ct)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	for key, want := range r.HeaderMatchers {
+		values, ok := ctx.Header[key]
+		if !ok {
+			return false
+		}
+		found := false
+		for _, v := range values {
+			if strings.Contains(v, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// containsFold 大小写不敏感地检查切片是否包含目标字符串
+func containsFold(list []string, target string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match 检查规则是否匹配内容。ctx为nil时跳过When表达式求值（兼容不带请求/响应元数据的调用方，
+// 如replacer.CompiledRules预筛后的直接Apply调用），仅由proxy层在拿到EngineContext时传入以启用When条件
+func (r *Rule) Match(ctx *EngineContext, content string) bool {
 	if !r.Enabled {
 		return false
 	}
 
+	if ctx != nil && r.compiledWhen != nil && !evalWhen(r.compiledWhen, ctx, content) {
+		return false
+	}
+
 	return r.matchString(content)
 }
 
-// Apply 应用规则到内容
-func (r *Rule) Apply(content string) string {
-	if r.Action != ActionDeleteJsonField && !r.Match(content) {
+// Apply 应用规则到content中由Path限定的JSON子树（Path为空时作用于整个content）
+func (r *Rule) Apply(ctx *EngineContext, content string) string {
+	if r.Path == "" {
+		return r.applyToTarget(ctx, content)
+	}
+	return r.applyToJSONPath(ctx, content)
+}
+
+// applyToTarget 对单个文本片段应用规则（delete/replace/delete_json_field/json_merge_patch/json_patch之一）
+func (r *Rule) applyToTarget(ctx *EngineContext, content string) string {
+	// delete_json_field和json_merge_patch/json_patch都不依赖Mode/Pattern的字面量或正则匹配，
+	// 而是分别检查JSON字段值、或直接把Value当补丁应用到整个content；但When条件对这几类动作依然生效
+	skipsMatch := r.Action == ActionDeleteJsonField || r.Action == ActionJsonMerge || r.Action == ActionJsonStrategicPatch
+	if skipsMatch {
+		if ctx != nil && r.compiledWhen != nil && !evalWhen(r.compiledWhen, ctx, content) {
+			return content
+		}
+	} else if !r.Match(ctx, content) {
 		return content
 	}
 
@@ -321,11 +642,154 @@ func (r *Rule) Apply(content string) string {
 		return r.replaceContent(content)
 	case ActionDeleteJsonField:
 		return r.deleteJsonField(content)
+	case ActionJsonMerge, ActionJsonStrategicPatch:
+		// 单条调用走独立的Unmarshal/Marshal；多条json_merge_patch/json_patch规则一起应用时，
+		// 调用方应改用ApplyJSONMergeRules在同一次解码中依次应用，避免反复编解码整个body
+		merged, err := ApplyJSONMergeRules(content, []Rule{*r})
+		if err != nil {
+			return content
+		}
+		return merged
 	default:
 		return content
 	}
 }
 
+// applyToJSONPath 将规则限定作用于content中由Path描述的JSON子树。Path为点分段路径，
+// 段为"*"时对map的所有value或array的所有元素展开（即请求支持的"数组返回多个目标"），
+// 段为数字时按下标定位数组元素，其余段按map的key定位；找不到的路径原样跳过，不报错
+func (r *Rule) applyToJSONPath(ctx *EngineContext, content string) string {
+	var data interface{}
+	if err := json.Unmarshal([]byte(content), &data); err != nil {
+		return content
+	}
+
+	changed := false
+	newData := r.walkJSONPath(data, strings.Split(r.Path, "."), ctx, &changed)
+	if !changed {
+		return content
+	}
+
+	out, err := json.MarshalIndent(newData, "", "  ")
+	if err != nil {
+		return content
+	}
+	return string(out)
+}
+
+// walkJSONPath 沿着segments递归定位Path指向的节点，到达叶子节点时调用applyLeafValue
+// （delete/replace）或按shouldDeleteLeaf判断是否要从父容器中摘除该字段/元素（delete_json_field）
+func (r *Rule) walkJSONPath(node interface{}, segments []string, ctx *EngineContext, changed *bool) interface{} {
+	seg := segments[0]
+	rest := segments[1:]
+	leaf := len(rest) == 0
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		keys := []string{seg}
+		if seg == "*" {
+			keys = make([]string, 0, len(n))
+			for k := range n {
+				keys = append(keys, k)
+			}
+		}
+
+		result := make(map[string]interface{}, len(n))
+		for k, v := range n {
+			result[k] = v
+		}
+		for _, k := range keys {
+			v, ok := n[k]
+			if !ok {
+				continue
+			}
+			if leaf && r.Action == ActionDeleteJsonField {
+				if r.shouldDeleteLeaf(v, ctx) {
+					delete(result, k)
+					*changed = true
+				}
+				continue
+			}
+			if leaf {
+				result[k] = r.applyLeafValue(v, ctx, changed)
+			} else {
+				result[k] = r.walkJSONPath(v, rest, ctx, changed)
+			}
+		}
+		return result
+
+	case []interface{}:
+		indices := []int{}
+		if seg == "*" {
+			for i := range n {
+				indices = append(indices, i)
+			}
+		} else if idx, err := strconv.Atoi(seg); err == nil && idx >= 0 && idx < len(n) {
+			indices = append(indices, idx)
+		}
+
+		removed := make(map[int]bool, len(indices))
+		result := make([]interface{}, len(n))
+		copy(result, n)
+		for _, idx := range indices {
+			if leaf && r.Action == ActionDeleteJsonField {
+				if r.shouldDeleteLeaf(n[idx], ctx) {
+					removed[idx] = true
+					*changed = true
+				}
+				continue
+			}
+			if leaf {
+				result[idx] = r.applyLeafValue(n[idx], ctx, changed)
+			} else {
+				result[idx] = r.walkJSONPath(n[idx], rest, ctx, changed)
+			}
+		}
+		if len(removed) == 0 {
+			return result
+		}
+		filtered := make([]interface{}, 0, len(result)-len(removed))
+		for i, v := range result {
+			if !removed[i] {
+				filtered = append(filtered, v)
+			}
+		}
+		return filtered
+
+	default:
+		return node
+	}
+}
+
+// shouldDeleteLeaf 判断delete_json_field规则是否命中了这个叶子节点的值（仅对字符串值生效）
+func (r *Rule) shouldDeleteLeaf(v interface{}, ctx *EngineContext) bool {
+	s, ok := v.(string)
+	return ok && r.Match(ctx, s)
+}
+
+// applyLeafValue 对Path定位到的单个叶子值应用delete/replace（非字符串值原样返回）
+func (r *Rule) applyLeafValue(node interface{}, ctx *EngineContext, changed *bool) interface{} {
+	s, ok := node.(string)
+	if !ok || !r.Match(ctx, s) {
+		return node
+	}
+
+	var newVal string
+	switch r.Action {
+	case ActionDelete:
+		newVal = r.deleteContent(s)
+	case ActionReplace:
+		newVal = r.replaceContent(s)
+	default:
+		return node
+	}
+
+	if newVal != s {
+		*changed = true
+	}
+	return newVal
+}
+
 // deleteContent 删除匹配的内容
 func (r *Rule) deleteContent(content string) string {
 	switch r.Mode {
@@ -340,8 +804,9 @@ func (r *Rule) deleteContent(content string) string {
 	case ModeContains:
 		return strings.ReplaceAll(content, r.Pattern, "")
 	case ModeRegex:
-		re := regexp.MustCompile(r.Pattern)
-		return re.ReplaceAllString(content, "")
+		if re := r.regex(); re != nil {
+			return re.ReplaceAllString(content, "")
+		}
 	}
 	return content
 }
@@ -360,8 +825,9 @@ func (r *Rule) replaceContent(content string) string {
 	case ModeContains:
 		return strings.ReplaceAll(content, r.Pattern, r.Value)
 	case ModeRegex:
-		re := regexp.MustCompile(r.Pattern)
-		return re.ReplaceAllString(content, r.Value)
+		if re := r.regex(); re != nil {
+			return re.ReplaceAllString(content, r.Value)
+		}
 	}
 	return content
 }
@@ -421,6 +887,20 @@ func (r *Rule) recursiveDelete(node interface{}) (interface{}, bool) {
 	}
 }
 
+// regex 返回mode=regex规则的已编译正则，优先使用CompileScope/validateRule阶段缓存的结果，
+// 避免Match/Apply在每次请求上都重新regexp.Compile；仅当规则未经过正常加载流程（如单测直接构造）时才临时编译
+func (r *Rule) regex() *regexp.Regexp {
+	if r.compiledRegex != nil {
+		return r.compiledRegex
+	}
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return nil
+	}
+	r.compiledRegex = re
+	return re
+}
+
 // matchString 根据规则模式检查字符串
 func (r *Rule) matchString(s string) bool {
 	switch r.Mode {
@@ -431,8 +911,8 @@ func (r *Rule) matchString(s string) bool {
 	case ModeSuffix:
 		return strings.HasSuffix(s, r.Pattern)
 	case ModeRegex:
-		matched, _ := regexp.MatchString(r.Pattern, s)
-		return matched
+		re := r.regex()
+		return re != nil && re.MatchString(s)
 	default:
 		return false
 	}
@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+)
+
+// httpSinkQueueSize 是HTTP sink异步发送队列的缓冲区大小；队列写满时直接丢弃新日志行，
+// 避免日志转发的网络延迟反向拖慢请求处理路径
+const httpSinkQueueSize = 1024
+
+// httpSinkWriter 把写入的日志行异步POST到一个HTTP端点（例如日志采集网关），
+// 在队列打满时选择丢弃而不是阻塞，因为日志转发不能影响主请求路径的延迟
+type httpSinkWriter struct {
+	url    string
+	client *http.Client
+	lines  chan []byte
+	done   chan struct{}
+}
+
+// newHTTPSinkWriter 启动一个后台goroutine持续把lines通道里的日志行POST到url
+func newHTTPSinkWriter(url string) *httpSinkWriter {
+	w := &httpSinkWriter{
+		url:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+		lines:  make(chan []byte, httpSinkQueueSize),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *httpSinkWriter) Write(p []byte) (int, error) {
+	line := make([]byte, len(p))
+	copy(line, p)
+
+	select {
+	case w.lines <- line:
+	default:
+		// 队列已满，丢弃本行，不阻塞调用方
+	}
+	return len(p), nil
+}
+
+func (w *httpSinkWriter) run() {
+	for {
+		select {
+		case line := <-w.lines:
+			resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(line))
+			if err == nil {
+				resp.Body.Close()
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Close 停止后台发送goroutine
+func (w *httpSinkWriter) Close() error {
+	close(w.done)
+	return nil
+}
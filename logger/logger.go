@@ -1,6 +1,8 @@
 package logger
 
 import (
+	"content_replace/config"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,34 +10,89 @@ import (
 	"os"
 	"strings"
 	"time"
-	
+
 	"github.com/fatih/color"
 )
 
+// Level 日志级别，数值越大越严重，SetLevel/currentLevel用它做过滤：
+// 当前级别以下的日志调用会被直接丢弃，不会格式化也不会写出
+type Level int
+
+const (
+	LevelTrace Level = iota
+	LevelDebug
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// parseLevel 把配置里的级别字符串解析为Level，无法识别时回退到info，不让配置错误导致日志全灭或刷屏
+func parseLevel(s string) Level {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "trace":
+		return LevelTrace
+	case "debug":
+		return LevelDebug
+	case "warn", "warning":
+		return LevelWarn
+	case "error":
+		return LevelError
+	default:
+		return LevelInfo
+	}
+}
+
+func (l Level) String() string {
+	switch l {
+	case LevelTrace:
+		return "trace"
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
 var (
-	debugMode = false
+	currentLevel Level = LevelInfo
+	jsonMode     bool
+	dumpsEnabled bool // 对应cfg.Debug.Enabled，控制LogOriginalRequest等完整内容dump，与currentLevel是两个独立的开关
+
 	infoLogger  *log.Logger
 	debugLogger *log.Logger
+	warnLogger  *log.Logger
 	errorLogger *log.Logger
+
+	// rawOut是Init组装好的原始输出目的地（不带任何前缀/时间戳），emitJSON直接写到这里，
+	// 避免结构化JSON行被infoLogger的"[INFO] "前缀和LstdFlags时间戳污染导致无法被JSON管道解析
+	rawOut io.Writer
+
+	closers []io.Closer // Init打开的文件/syslog/http sink句柄，供进程退出时按需清理
 )
 
 // 颜色定义
 var (
 	// 规则匹配状态颜色
-	green        = color.New(color.FgGreen).SprintFunc()     // 绿色：成功/匹配
-	red          = color.New(color.FgRed).SprintFunc()       // 红色：错误/未匹配
-	yellow       = color.New(color.FgYellow).SprintFunc()    // 黄色：警告
-	blue         = color.New(color.FgBlue).SprintFunc()      // 蓝色：信息
-	cyan         = color.New(color.FgCyan).SprintFunc()      // 青色：请求信息
-	magenta      = color.New(color.FgMagenta).SprintFunc()   // 洋红：特殊标记
-	
+	green   = color.New(color.FgGreen).SprintFunc()   // 绿色：成功/匹配
+	red     = color.New(color.FgRed).SprintFunc()     // 红色：错误/未匹配
+	yellow  = color.New(color.FgYellow).SprintFunc()  // 黄色：警告
+	blue    = color.New(color.FgBlue).SprintFunc()     // 蓝色：信息
+	cyan    = color.New(color.FgCyan).SprintFunc()    // 青色：请求信息
+	magenta = color.New(color.FgMagenta).SprintFunc() // 洋红：特殊标记
+
 	// 加粗版本
-	boldGreen    = color.New(color.FgGreen, color.Bold).SprintFunc()
-	boldRed      = color.New(color.FgRed, color.Bold).SprintFunc()
-	boldYellow   = color.New(color.FgYellow, color.Bold).SprintFunc()
-	boldBlue     = color.New(color.FgBlue, color.Bold).SprintFunc()
-	boldCyan     = color.New(color.FgCyan, color.Bold).SprintFunc()
-	
+	boldGreen  = color.New(color.FgGreen, color.Bold).SprintFunc()
+	boldRed    = color.New(color.FgRed, color.Bold).SprintFunc()
+	boldYellow = color.New(color.FgYellow, color.Bold).SprintFunc()
+	boldBlue   = color.New(color.FgBlue, color.Bold).SprintFunc()
+	boldCyan   = color.New(color.FgCyan, color.Bold).SprintFunc()
+
 	// 状态码颜色
 	successColor = color.New(color.FgGreen, color.Bold).SprintFunc()
 	errorColor   = color.New(color.FgRed, color.Bold).SprintFunc()
@@ -43,59 +100,188 @@ var (
 	infoColor    = color.New(color.FgBlue, color.Bold).SprintFunc()
 )
 
-// Init 初始化日志系统
-func Init(debug bool) {
-	debugMode = debug
-	
-	// 创建日志目录
-	if err := os.MkdirAll("logs", 0755); err != nil {
-		log.Fatalf("无法创建日志目录: %v", err)
+// Init 初始化日志系统：按cfg.Level设置过滤级别，按cfg.Format选择文本/JSON输出，
+// 按cfg.MaxSizeMB/MaxAgeDays/MaxBackups启用文件滚动，并视配置开启syslog/HTTP sink转发。
+// debugDumpsEnabled对应cfg.Debug.Enabled，只控制LogOriginalRequest等完整请求/响应内容dump，
+// 与日志级别过滤是两个维度的开关
+func Init(cfg config.LoggingConfig, debugDumpsEnabled bool) error {
+	currentLevel = parseLevel(cfg.Level)
+	jsonMode = strings.EqualFold(cfg.Format, "json")
+	dumpsEnabled = debugDumpsEnabled
+
+	writers, err := buildWriters(cfg)
+	if err != nil {
+		return fmt.Errorf("初始化日志输出失败: %v", err)
+	}
+	out := io.MultiWriter(writers...)
+	rawOut = out
+
+	infoLogger = log.New(out, "[INFO] ", log.LstdFlags)
+	debugLogger = log.New(out, "[DEBUG] ", log.LstdFlags)
+	warnLogger = log.New(out, "[WARN] ", log.LstdFlags)
+	errorLogger = log.New(out, "[ERROR] ", log.LstdFlags)
+
+	return nil
+}
+
+// buildWriters 按配置组装日志输出目的地：标准输出始终保留，另外视配置叠加滚动文件/syslog/HTTP sink
+func buildWriters(cfg config.LoggingConfig) ([]io.Writer, error) {
+	writers := []io.Writer{os.Stdout}
+	closers = nil
+
+	if cfg.File != "" {
+		if err := os.MkdirAll(dirOf(cfg.File), 0755); err != nil {
+			return nil, fmt.Errorf("创建日志目录失败: %v", err)
+		}
+		fileWriter, err := newRotatingWriter(cfg.File, cfg.MaxSizeMB, cfg.MaxAgeDays, cfg.MaxBackups)
+		if err != nil {
+			return nil, fmt.Errorf("打开日志文件失败: %v", err)
+		}
+		writers = append(writers, fileWriter)
+		closers = append(closers, fileWriter)
+	}
+
+	if cfg.Syslog.Enabled {
+		syslogWriter, err := newSyslogWriter(cfg.Syslog)
+		if err != nil {
+			return nil, fmt.Errorf("连接syslog失败: %v", err)
+		}
+		writers = append(writers, syslogWriter)
+		if c, ok := syslogWriter.(io.Closer); ok {
+			closers = append(closers, c)
+		}
 	}
-	
-	// 初始化不同级别的日志器
-	infoLogger = log.New(os.Stdout, "[INFO] ", log.LstdFlags)
-	debugLogger = log.New(os.Stdout, "[DEBUG] ", log.LstdFlags)
-	errorLogger = log.New(os.Stderr, "[ERROR] ", log.LstdFlags)
-	
-	// 如果不是debug模式，禁用debug日志输出
-	if !debugMode {
-		debugLogger = log.New(io.Discard, "[DEBUG] ", log.LstdFlags)
+
+	if cfg.HTTPSink != "" {
+		sink := newHTTPSinkWriter(cfg.HTTPSink)
+		writers = append(writers, sink)
+		closers = append(closers, sink)
 	}
+
+	return writers, nil
 }
 
-// Info 记录信息日志
-func Info(format string, v ...interface{}) {
-	infoLogger.Printf(format, v...)
+// dirOf 返回文件路径所在目录，用于MkdirAll；手写而不是引入path/filepath以外的依赖
+func dirOf(path string) string {
+	idx := strings.LastIndexByte(path, '/')
+	if idx < 0 {
+		return "."
+	}
+	return path[:idx]
 }
 
-// Infof 格式化信息日志
-func Infof(format string, v ...interface{}) {
-	infoLogger.Printf(format, v...)
+// Close 关闭Init打开的文件/syslog/http sink句柄，供main.go在进程退出前调用
+func Close() {
+	for _, c := range closers {
+		_ = c.Close()
+	}
+	closers = nil
 }
 
-// Debug 记录调试日志
-func Debug(format string, v ...interface{}) {
-	debugLogger.Printf(format, v...)
+// requestIDCtxKey 是context.Context中存放请求关联ID的私有键类型；整个模块通过WithRequestID/
+// RequestIDFromContext共用同一个键，使proxy包的请求处理链路和logger包的With()能互通
+type requestIDCtxKey struct{}
+
+// WithRequestID 把requestID写入context，使下游任意持有该ctx的代码都能通过logger.With(ctx)
+// 取到同一个ID，将属于同一次请求的日志在聚合系统里关联起来
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, requestID)
 }
 
-// Debugf 格式化调试日志
-func Debugf(format string, v ...interface{}) {
-	if debugMode {
-		debugLogger.Printf(format, v...)
+// RequestIDFromContext 从context中取出请求关联ID，取不到时返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDCtxKey{}).(string); ok {
+		return id
 	}
+	return ""
 }
 
-// Error 记录错误日志
-func Error(format string, v ...interface{}) {
-	errorLogger.Printf(format, v...)
+// Entry 绑定了某个请求关联ID的日志句柄，其Tracef/Debugf/Infof/Warnf/Errorf输出的每一条日志
+// 都带上同一个request_id字段，使ForwardRequest、规则匹配、CopyResponse等跨函数调用产生的日志
+// 能在日志聚合系统里按同一次请求串联起来
+type Entry struct {
+	requestID string
+}
+
+// With 从ctx中取出request_id（由WithRequestID写入），返回绑定了该ID的日志句柄；
+// ctx里没有ID时等价于顶层的Tracef/Debugf/...函数
+func With(ctx context.Context) *Entry {
+	return &Entry{requestID: RequestIDFromContext(ctx)}
 }
 
+// WithID 直接用一个已知的请求关联ID构造日志句柄，供只持有字符串ID（如config.EngineContext.RequestID）
+// 而非context.Context的调用方（如replacer.Engine.Process）使用
+func WithID(requestID string) *Entry {
+	return &Entry{requestID: requestID}
+}
+
+func (e *Entry) Tracef(format string, v ...interface{}) { logf(LevelTrace, e.requestID, format, v...) }
+func (e *Entry) Debugf(format string, v ...interface{}) { logf(LevelDebug, e.requestID, format, v...) }
+func (e *Entry) Infof(format string, v ...interface{})  { logf(LevelInfo, e.requestID, format, v...) }
+func (e *Entry) Warnf(format string, v ...interface{})  { logf(LevelWarn, e.requestID, format, v...) }
+func (e *Entry) Errorf(format string, v ...interface{}) { logf(LevelError, e.requestID, format, v...) }
+
+// logf 是所有级别日志的统一入口：先按currentLevel过滤，再按jsonMode选择结构化或彩色文本输出
+func logf(level Level, requestID, format string, v ...interface{}) {
+	if level < currentLevel {
+		return
+	}
+
+	msg := fmt.Sprintf(format, v...)
+
+	if jsonMode {
+		writeJSONLine(level, requestID, msg, nil)
+		return
+	}
+
+	if requestID != "" {
+		msg = fmt.Sprintf("[%s] %s", requestID, msg)
+	}
+	switch level {
+	case LevelTrace, LevelDebug:
+		debugLogger.Printf("%s", msg)
+	case LevelWarn:
+		warnLogger.Printf("%s", msg)
+	case LevelError:
+		errorLogger.Printf("%s", msg)
+	default:
+		infoLogger.Printf("%s", msg)
+	}
+}
+
+// Trace 记录trace级别日志，用于比debug更细粒度、默认不开启的排查信息
+func Trace(format string, v ...interface{}) { logf(LevelTrace, "", format, v...) }
+
+// Tracef 同Trace
+func Tracef(format string, v ...interface{}) { logf(LevelTrace, "", format, v...) }
+
+// Info 记录信息日志
+func Info(format string, v ...interface{}) { logf(LevelInfo, "", format, v...) }
+
+// Infof 格式化信息日志
+func Infof(format string, v ...interface{}) { logf(LevelInfo, "", format, v...) }
+
+// Warn 记录警告日志
+func Warn(format string, v ...interface{}) { logf(LevelWarn, "", format, v...) }
+
+// Warnf 同Warn
+func Warnf(format string, v ...interface{}) { logf(LevelWarn, "", format, v...) }
+
+// Debug 记录调试日志
+func Debug(format string, v ...interface{}) { logf(LevelDebug, "", format, v...) }
+
+// Debugf 格式化调试日志
+func Debugf(format string, v ...interface{}) { logf(LevelDebug, "", format, v...) }
+
+// Error 记录错误日志
+func Error(format string, v ...interface{}) { logf(LevelError, "", format, v...) }
+
 // LogOriginalRequest 记录原始请求内容
 func LogOriginalRequest(method, path string, headers map[string][]string, body string) {
-	if !debugMode {
+	if !dumpsEnabled {
 		return
 	}
-	
+
 	Debugf("%s", boldYellow("=== 原始请求 ==="))
 	Debugf("方法: %s", boldCyan(method))
 	Debugf("路径: %s", cyan(path))
@@ -115,17 +301,13 @@ func LogOriginalRequest(method, path string, headers map[string][]string, body s
 
 // LogModifiedRequest 记录修改后的请求内容
 func LogModifiedRequest(method, path string, headers map[string][]string, body string) {
-	if !debugMode {
+	if !dumpsEnabled {
 		return
 	}
-	
+
 	Debugf("%s", boldGreen("=== 修改后请求 ==="))
 	Debugf("方法: %s", boldCyan(method))
 	Debugf("路径: %s", cyan(path))
-//	Debugf("%s", blue("Headers:"))
-//	for k, v := range headers {
-//		Debugf("  %s: %v", green(k), yellow(v))
-//	}
 	Debugf("%s", blue("Body内容:"))
 	if len(body) > 0 {
 		compressedBody := compressJSONContent(body)
@@ -138,13 +320,13 @@ func LogModifiedRequest(method, path string, headers map[string][]string, body s
 
 // LogRuleMatch 记录规则匹配情况
 func LogRuleMatch(ruleName, mode, pattern, action, value string, matched bool) {
-	if !debugMode {
+	if !dumpsEnabled {
 		return
 	}
-	
+
 	var status string
 	var coloredRuleName, coloredStatus string
-	
+
 	if matched {
 		status = "✓ 匹配"
 		coloredRuleName = green(ruleName)
@@ -154,34 +336,30 @@ func LogRuleMatch(ruleName, mode, pattern, action, value string, matched bool) {
 		coloredRuleName = red(ruleName)
 		coloredStatus = red(status)
 	}
-	
+
 	// 截断 pattern 和 value，最大显示30个字符
 	truncatedPattern := truncateString(pattern, 30)
 	truncatedValue := truncateString(value, 30)
-	
+
 	coloredMode := blue(mode)
 	coloredPattern := cyan(truncatedPattern)
 	coloredValue := magenta(truncatedValue)
-	
+
 	Debugf("规则匹配: %s | 模式: %s | 匹配内容: %s | 替换值: %s | 状态: %s",
 		coloredRuleName, coloredMode, coloredPattern, coloredValue, coloredStatus)
 }
 
 // LogRuleApplied 记录规则应用结果
 func LogRuleApplied(ruleName, originalContent, modifiedContent string) {
-	if !debugMode {
+	if !dumpsEnabled {
 		return
 	}
-	
+
 	Debugf("规则应用: %s", boldGreen(ruleName))
-	
+
 	// 对原始内容片段应用JSON压缩
 	compressedOriginal := compressJSONContent(originalContent)
 	Debugf("原始内容片段: %s", red(compressedOriginal))
-	
-	// 对修改后内容片段应用JSON压缩
-	//compressedModified := compressJSONContent(modifiedContent)
-	//Debugf("修改后内容片段: %s", green(compressedModified))
 }
 
 // LogRequestStart 记录请求开始处理
@@ -192,7 +370,7 @@ func LogRequestStart(requestID, method, path string) {
 // LogRequestEnd 记录请求处理完成
 func LogRequestEnd(requestID string, statusCode int, duration time.Duration) {
 	var coloredStatusCode string
-	
+
 	switch {
 	case statusCode >= 200 && statusCode < 300:
 		// 2xx 成功状态码 - 绿色
@@ -210,10 +388,10 @@ func LogRequestEnd(requestID string, statusCode int, duration time.Duration) {
 		// 其他状态码 - 蓝色
 		coloredStatusCode = infoColor(fmt.Sprintf("%d", statusCode))
 	}
-	
+
 	coloredRequestID := cyan(requestID)
 	coloredDuration := blue(duration.String())
-	
+
 	Debugf("请求处理完成 [%s] 状态码: %s 耗时: %s",
 		coloredRequestID, coloredStatusCode, coloredDuration)
 }
@@ -223,20 +401,21 @@ func truncateString(s string, maxLength int) string {
 	if len(s) <= maxLength {
 		return s
 	}
-	
+
 	if maxLength <= 30 {
 		return s[:maxLength] // 如果最大长度小于等于3，直接截断不添加省略号
 	}
-	
+
 	return s[:maxLength-30] + "..."
 }
+
 // compressJSONContent 压缩 JSON 格式化内容
 func compressJSONContent(content string) string {
 	// 检查是否包含换行符（JSON 格式化的特征）
 	if !strings.Contains(content, "\n") {
 		return content // 如果没有换行符，可能已经是压缩格式
 	}
-	
+
 	// 尝试解析为 JSON 来验证格式
 	trimmed := strings.TrimSpace(content)
 	if (strings.HasPrefix(trimmed, "{") && strings.HasSuffix(trimmed, "}")) ||
@@ -250,14 +429,68 @@ func compressJSONContent(content string) string {
 			}
 		}
 	}
-	
+
 	// 如果不是有效的 JSON，只移除换行符，保留原有内容
 	result := strings.ReplaceAll(content, "\n", " ")
 	result = strings.ReplaceAll(result, "\r", " ")
 	return result
 }
 
-// IsDebugEnabled 检查是否启用debug模式
+// IsDebugEnabled 检查是否启用调试内容dump（LogOriginalRequest等），与日志级别过滤是两回事
 func IsDebugEnabled() bool {
-	return debugMode
-}
\ No newline at end of file
+	return dumpsEnabled
+}
+
+// structuredEntry 是LogStructured/JSON模式日志输出的JSON行结构，requestID用于把同一次请求的
+// 多条日志（请求处理/转发/规则应用）在日志聚合系统里关联起来
+type structuredEntry struct {
+	Time      string                 `json:"time"`
+	Level     string                 `json:"level,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Event     string                 `json:"event"`
+	Message   string                 `json:"message,omitempty"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// writeJSONLine 序列化并输出一条结构化日志行，供logf（JSON模式下的Tracef/Debugf/...）和
+// LogStructured共用
+func writeJSONLine(level Level, requestID, message string, fields map[string]interface{}) {
+	entry := structuredEntry{
+		Time:      time.Now().Format(time.RFC3339Nano),
+		Level:     level.String(),
+		RequestID: requestID,
+		Event:     "log",
+		Message:   message,
+		Fields:    fields,
+	}
+	emitJSON(entry)
+}
+
+func emitJSON(entry structuredEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		errorLogger.Printf("结构化日志序列化失败: %v", err)
+		return
+	}
+	data = append(data, '\n')
+	// 直接写到rawOut，不经过infoLogger——走infoLogger会被强加"[INFO] "前缀和LstdFlags时间戳，
+	// 把这行JSON变成非法JSON，下游日志管道就解析不了了
+	if rawOut == nil {
+		os.Stdout.Write(data)
+		return
+	}
+	if _, err := rawOut.Write(data); err != nil {
+		errorLogger.Printf("结构化日志写出失败: %v", err)
+	}
+}
+
+// LogStructured 以JSON行的形式输出一条带requestID关联的结构化日志，供日志采集/链路追踪系统消费，
+// 与面向终端阅读的彩色Debug日志互不影响，始终输出（不受currentLevel/jsonMode控制）
+func LogStructured(requestID, event string, fields map[string]interface{}) {
+	emitJSON(structuredEntry{
+		Time:      time.Now().Format(time.RFC3339Nano),
+		RequestID: requestID,
+		Event:     event,
+		Fields:    fields,
+	})
+}
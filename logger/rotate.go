@@ -0,0 +1,135 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatingWriter 是一个按大小和保留时长滚动的io.Writer，手写实现而不引入第三方依赖：
+// 写入超过maxSizeMB时把当前文件重命名为带时间戳的备份，再开一个新文件继续写；
+// 每次滚动时同时清理超过maxAgeDays或超过maxBackups数量的旧备份
+type rotatingWriter struct {
+	mu          sync.Mutex
+	path        string
+	maxSizeMB   int
+	maxAgeDays  int
+	maxBackups  int
+	file        *os.File
+	currentSize int64
+}
+
+// newRotatingWriter 打开（或创建）path用于追加写入，maxSizeMB<=0表示不按大小滚动，
+// maxAgeDays/maxBackups<=0表示对应维度不清理
+func newRotatingWriter(path string, maxSizeMB, maxAgeDays, maxBackups int) (*rotatingWriter, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &rotatingWriter{
+		path:        path,
+		maxSizeMB:   maxSizeMB,
+		maxAgeDays:  maxAgeDays,
+		maxBackups:  maxBackups,
+		file:        f,
+		currentSize: info.Size(),
+	}, nil
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.maxSizeMB > 0 && w.currentSize+int64(len(p)) > int64(w.maxSizeMB)*1024*1024 {
+		if err := w.rotate(); err != nil {
+			return 0, fmt.Errorf("日志滚动失败: %v", err)
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.currentSize += int64(n)
+	return n, err
+}
+
+// rotate 关闭当前文件，将其重命名为带时间戳的备份文件，再以追加模式打开一个新文件，
+// 并清理超期/超量的旧备份
+func (w *rotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	backupPath := fmt.Sprintf("%s.%s", w.path, time.Now().Format("20060102-150405"))
+	if err := os.Rename(w.path, backupPath); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.currentSize = 0
+
+	w.cleanupBackups()
+	return nil
+}
+
+// cleanupBackups 删除超过maxAgeDays天的备份文件，以及超出maxBackups数量限制的最旧备份
+func (w *rotatingWriter) cleanupBackups() {
+	dir := dirOf(w.path)
+	base := w.path[strings.LastIndexByte(w.path, '/')+1:]
+	prefix := base + "."
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	var backups []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), prefix) {
+			continue
+		}
+		backups = append(backups, dir+"/"+e.Name())
+	}
+	sort.Strings(backups)
+
+	if w.maxAgeDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -w.maxAgeDays)
+		kept := backups[:0]
+		for _, b := range backups {
+			info, err := os.Stat(b)
+			if err != nil {
+				continue
+			}
+			if info.ModTime().Before(cutoff) {
+				os.Remove(b)
+				continue
+			}
+			kept = append(kept, b)
+		}
+		backups = kept
+	}
+
+	if w.maxBackups > 0 && len(backups) > w.maxBackups {
+		toRemove := backups[:len(backups)-w.maxBackups]
+		for _, b := range toRemove {
+			os.Remove(b)
+		}
+	}
+}
+
+// Close 关闭底层文件句柄
+func (w *rotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
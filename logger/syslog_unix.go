@@ -0,0 +1,31 @@
+//go:build !windows
+
+package logger
+
+import (
+	"content_replace/config"
+	"fmt"
+	"io"
+	"log/syslog"
+)
+
+// newSyslogWriter 通过标准库log/syslog转发日志到本地或远程syslog守护进程；
+// Network/Addr为空时走本地syslog（类似直接调syslog(3)），否则按配置的网络协议拨号远程syslog服务器
+func newSyslogWriter(cfg config.SyslogConfig) (io.WriteCloser, error) {
+	tag := cfg.Tag
+	if tag == "" {
+		tag = "content_replace"
+	}
+
+	var w *syslog.Writer
+	var err error
+	if cfg.Network != "" && cfg.Address != "" {
+		w, err = syslog.Dial(cfg.Network, cfg.Address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	} else {
+		w, err = syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("连接syslog失败: %v", err)
+	}
+	return w, nil
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package logger
+
+import (
+	"content_replace/config"
+	"fmt"
+	"io"
+)
+
+// newSyslogWriter 在Windows上没有等价于log/syslog的标准库支持，直接返回错误，
+// 由调用方（logger.Init）决定是否中止启动；syslog转发本质上是类Unix概念
+func newSyslogWriter(cfg config.SyslogConfig) (io.WriteCloser, error) {
+	return nil, fmt.Errorf("syslog转发在Windows平台不受支持")
+}
@@ -23,8 +23,12 @@ func main() {
 		log.Fatalf("加载配置文件失败: %v", err)
 	}
 
-	// 初始化日志系统（使用配置文件中的debug设置）
-	logger.Init(cfg.Debug.Enabled)
+	// 初始化日志系统：cfg.Logging控制级别/格式/滚动/syslog/HTTP sink，cfg.Debug.Enabled
+	// 单独控制LogOriginalRequest等完整内容dump
+	if err := logger.Init(cfg.Logging, cfg.Debug.Enabled); err != nil {
+		log.Fatalf("初始化日志系统失败: %v", err)
+	}
+	defer logger.Close()
 	logger.Info("启动HTTP内容替换代理服务器")
 	logger.Info("配置加载成功")
 	logger.Debugf("服务器配置: %+v", cfg.Server)
@@ -40,6 +44,17 @@ func main() {
 		}
 	}()
 
+	// 如果启用了管理接口，启动独立的控制面监听
+	var adminServer *proxy.AdminServer
+	if cfg.Admin.Enabled {
+		adminServer = proxy.NewAdminServer(server)
+		go func() {
+			if err := adminServer.Start(); err != nil {
+				logger.Error("启动管理接口服务器失败: %v", err)
+			}
+		}()
+	}
+
 	// 如果启用了自动重载，则启动文件监听器
 	var fileWatcher *watcher.Watcher
 	if cfg.Rules.AutoReload {
@@ -57,6 +72,7 @@ func main() {
 			if err != nil {
 				logger.Error("创建文件监听器失败: %v", err)
 			} else {
+				server.SetWatcher(fileWatcher)
 				fileWatcher.Start()
 			}
 		}
@@ -74,6 +90,12 @@ func main() {
 		fileWatcher.Stop()
 	}
 
+	if adminServer != nil {
+		if err := adminServer.Stop(); err != nil {
+			logger.Error("关闭管理接口服务器失败: %v", err)
+		}
+	}
+
 	server.Stop()
 	logger.Info("服务器已关闭")
 }
\ No newline at end of file
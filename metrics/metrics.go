@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// 所有指标使用同一个registry，避免重复注册（Init可能被多次调用，例如测试或重载场景）
+var (
+	registerOnce sync.Once
+
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "proxy_requests_total",
+		Help: "代理处理的请求总数，按方法、状态码、是否命中规则维度划分",
+	}, []string{"method", "status", "rule_matched"})
+
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "proxy_request_duration_seconds",
+		Help:    "代理处理单个请求的耗时分布",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+
+	RuleApplyTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "replacer_rule_apply_total",
+		Help: "每条规则实际生效（内容被修改）的次数，按规则名和动作类型划分",
+	}, []string{"rule", "mode"})
+
+	RuleMatchTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "proxy_rule_matches_total",
+		Help: "每条规则被判定为匹配的次数，按规则名划分（不要求内容实际发生变化，参见RuleApplyTotal）",
+	}, []string{"rule"})
+
+	UpstreamErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "proxy_upstream_errors_total",
+		Help: "转发到上游目标失败的次数，按目标和失败原因划分",
+	}, []string{"target", "reason"})
+
+	TargetHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "loadbalancer_target_healthy",
+		Help: "负载均衡目标的健康状态，1为健康，0为不健康",
+	}, []string{"target"})
+
+	TargetInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "loadbalancer_target_in_flight",
+		Help: "负载均衡目标当前正在处理的请求数",
+	}, []string{"target"})
+
+	TargetLatencySeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "loadbalancer_target_latency_seconds",
+		Help:    "负载均衡目标每次请求的耗时分布，供least_conn/p2c_ewma策略调参参考",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"target"})
+)
+
+// Handler 返回标准的Prometheus抓取端点handler，供管理接口挂载
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Init 目前只是为未来可能的延迟初始化/自定义registry预留入口，
+// 当前所有指标使用promauto在包加载时完成注册
+func Init() {
+	registerOnce.Do(func() {})
+}
+
+// SetTargetHealthy 记录某个目标的健康状态
+func SetTargetHealthy(target string, healthy bool) {
+	value := 0.0
+	if healthy {
+		value = 1.0
+	}
+	TargetHealthy.WithLabelValues(target).Set(value)
+}
+
+// SetTargetInFlight 记录某个目标当前的in-flight请求数
+func SetTargetInFlight(target string, inFlight int64) {
+	TargetInFlight.WithLabelValues(target).Set(float64(inFlight))
+}
+
+// ObserveTargetLatency 记录一次发往目标的请求耗时
+func ObserveTargetLatency(target string, seconds float64) {
+	TargetLatencySeconds.WithLabelValues(target).Observe(seconds)
+}
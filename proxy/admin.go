@@ -0,0 +1,375 @@
+package proxy
+
+import (
+	"content_replace/config"
+	"content_replace/logger"
+	"content_replace/metrics"
+	"content_replace/replacer"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AdminServer 管理接口服务器（控制面），与代理数据面监听端口分离，
+// 让运维可以在不重启进程的情况下查看/修改规则
+type AdminServer struct {
+	server *Server
+	http   *http.Server
+}
+
+// NewAdminServer 创建管理接口服务器，路由到既有的Server方法（GetEngine/ReloadConfig/GetStats/HealthCheck）
+func NewAdminServer(s *Server) *AdminServer {
+	admin := &AdminServer{server: s}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/rules", admin.handleRules)
+	mux.HandleFunc("/admin/rules/reload", admin.handleRulesReload)
+	mux.HandleFunc("/admin/rules/dryrun", admin.handleRulesDryRun)
+	mux.HandleFunc("/admin/rules/", admin.handleRuleAction)
+	mux.HandleFunc("/admin/reload", admin.handleReload)
+	mux.HandleFunc("/admin/stats", admin.handleStats)
+	mux.HandleFunc("/admin/health", admin.handleHealth)
+	mux.Handle("/admin/metrics", metrics.Handler())
+
+	admin.http = &http.Server{
+		Addr:         s.config.GetAdminAddress(),
+		Handler:      admin.withAuth(mux),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	return admin
+}
+
+// withAuth 在AdminConfig.Token非空时，要求所有管理接口请求携带匹配的Authorization: Bearer <token>
+func (a *AdminServer) withAuth(next http.Handler) http.Handler {
+	token := a.server.config.Admin.Token
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			writeAdminError(w, http.StatusUnauthorized, "缺少或无效的管理接口令牌")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Start 启动管理接口监听
+func (a *AdminServer) Start() error {
+	logger.Infof("启动管理接口服务器在 %s", a.server.config.GetAdminAddress())
+	if err := a.http.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("管理接口服务器启动失败: %v", err)
+	}
+	return nil
+}
+
+// Stop 停止管理接口监听
+func (a *AdminServer) Stop() error {
+	return a.http.Close()
+}
+
+// adminError 管理接口统一的结构化错误响应
+type adminError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		logger.Error("管理接口序列化响应失败: %v", err)
+	}
+}
+
+func writeAdminError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, adminError{Error: fmt.Sprintf(format, args...)})
+}
+
+// ruleView GET /admin/rules返回的单条规则视图，附带config.RuleID算出的稳定短ID，
+// 供调用方在后续toggle/delete时引用，而不必依赖容易随增删漂移的数组下标
+type ruleView struct {
+	ID string `json:"id"`
+	config.Rule
+}
+
+func toRuleViews(rules []config.Rule) []ruleView {
+	views := make([]ruleView, len(rules))
+	for i, rule := range rules {
+		views[i] = ruleView{ID: config.RuleID(rule), Rule: rule}
+	}
+	return views
+}
+
+// handleRules GET返回当前规则列表（带ID），PUT原子替换整个规则集（先校验再生效），
+// POST新增一条规则并持久化写回其归属文件
+func (a *AdminServer) handleRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, toRuleViews(a.server.GetEngine().GetRules()))
+	case http.MethodPut:
+		var rules []config.Rule
+		if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+			writeAdminError(w, http.StatusBadRequest, "解析请求体失败: %v", err)
+			return
+		}
+		if err := config.ValidateRules(rules); err != nil {
+			writeAdminError(w, http.StatusUnprocessableEntity, "规则校验失败: %v", err)
+			return
+		}
+		a.server.UpdateRules(rules)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"updated": len(rules)})
+	case http.MethodPost:
+		a.handleRuleCreate(w, r)
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, "不支持的方法: %s", r.Method)
+	}
+}
+
+// handleRuleCreate 新增一条规则：解析、校验、追加到当前规则集、写回其归属文件（默认取
+// RulesConfig.Files/File中的第一个路径），再原子生效
+func (a *AdminServer) handleRuleCreate(w http.ResponseWriter, r *http.Request) {
+	var rule config.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		writeAdminError(w, http.StatusBadRequest, "解析请求体失败: %v", err)
+		return
+	}
+
+	rules := append([]config.Rule{}, a.server.GetEngine().GetRules()...)
+	rule.SourceFile = a.defaultRulesFile()
+	rules = append(rules, rule)
+
+	if err := config.ValidateRules(rules); err != nil {
+		writeAdminError(w, http.StatusUnprocessableEntity, "规则校验失败: %v", err)
+		return
+	}
+	if err := a.persistRuleGroup(rules, rule.SourceFile); err != nil {
+		writeAdminError(w, http.StatusInternalServerError, "持久化规则失败: %v", err)
+		return
+	}
+
+	a.server.UpdateRules(rules)
+	writeJSON(w, http.StatusCreated, ruleView{ID: config.RuleID(rule), Rule: rule})
+}
+
+// handleRuleAction 处理 /admin/rules/:name/enable、/admin/rules/:name/disable
+// 和 /admin/rules/:id/toggle（按config.RuleID引用）、/admin/rules/:id（DELETE删除）
+func (a *AdminServer) handleRuleAction(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/admin/rules/")
+	parts := strings.Split(path, "/")
+
+	if r.Method == http.MethodDelete && len(parts) == 1 {
+		a.handleRuleDelete(w, r, parts[0])
+		return
+	}
+
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		writeAdminError(w, http.StatusMethodNotAllowed, "不支持的方法: %s", r.Method)
+		return
+	}
+	if len(parts) != 2 {
+		writeAdminError(w, http.StatusNotFound, "未知的管理接口路径: %s", r.URL.Path)
+		return
+	}
+
+	ref, action := parts[0], parts[1]
+	engine := a.server.GetEngine()
+
+	if action == "toggle" {
+		a.handleRuleToggle(w, ref)
+		return
+	}
+
+	var err error
+	switch action {
+	case "enable":
+		err = engine.EnableRule(ref)
+	case "disable":
+		err = engine.DisableRule(ref)
+	default:
+		writeAdminError(w, http.StatusNotFound, "未知的规则操作: %s", action)
+		return
+	}
+
+	if err != nil {
+		writeAdminError(w, http.StatusNotFound, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"name": ref, "action": action, "status": "ok"})
+}
+
+// handleRuleToggle 按config.RuleID查找规则并反转其Enabled状态，然后写回归属文件
+func (a *AdminServer) handleRuleToggle(w http.ResponseWriter, id string) {
+	rules := append([]config.Rule{}, a.server.GetEngine().GetRules()...)
+
+	idx := findRuleIndexByID(rules, id)
+	if idx < 0 {
+		writeAdminError(w, http.StatusNotFound, "未找到ID为%s的规则", id)
+		return
+	}
+
+	rules[idx].Enabled = !rules[idx].Enabled
+	if err := a.persistRuleGroup(rules, rules[idx].SourceFile); err != nil {
+		writeAdminError(w, http.StatusInternalServerError, "持久化规则失败: %v", err)
+		return
+	}
+
+	a.server.UpdateRules(rules)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"id": id, "enabled": rules[idx].Enabled, "status": "ok"})
+}
+
+// handleRuleDelete 按config.RuleID删除一条规则并写回归属文件
+func (a *AdminServer) handleRuleDelete(w http.ResponseWriter, r *http.Request, id string) {
+	rules := append([]config.Rule{}, a.server.GetEngine().GetRules()...)
+
+	idx := findRuleIndexByID(rules, id)
+	if idx < 0 {
+		writeAdminError(w, http.StatusNotFound, "未找到ID为%s的规则", id)
+		return
+	}
+
+	sourceFile := rules[idx].SourceFile
+	rules = append(rules[:idx], rules[idx+1:]...)
+
+	if err := a.persistRuleGroup(rules, sourceFile); err != nil {
+		writeAdminError(w, http.StatusInternalServerError, "持久化规则失败: %v", err)
+		return
+	}
+
+	a.server.UpdateRules(rules)
+	writeJSON(w, http.StatusOK, map[string]string{"id": id, "status": "deleted"})
+}
+
+func findRuleIndexByID(rules []config.Rule, id string) int {
+	for i, rule := range rules {
+		if config.RuleID(rule) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// defaultRulesFile 新增规则时默认写回的文件：优先取RulesConfig.Files的第一项，否则回退到File
+func (a *AdminServer) defaultRulesFile() string {
+	rulesCfg := a.server.config.Rules
+	if len(rulesCfg.Files) > 0 {
+		return rulesCfg.Files[0]
+	}
+	return rulesCfg.File
+}
+
+// persistRuleGroup 把属于同一个SourceFile的规则子集写回该文件，
+// 供新增/切换/删除规则后持久化，使变更在下次重载/重启后依然生效
+func (a *AdminServer) persistRuleGroup(rules []config.Rule, path string) error {
+	if path == "" {
+		return fmt.Errorf("规则未关联任何来源文件，无法持久化")
+	}
+	var group []config.Rule
+	for _, rule := range rules {
+		if rule.SourceFile == path {
+			group = append(group, rule)
+		}
+	}
+	return config.SaveRulesToFile(path, group)
+}
+
+// handleRulesReload 仅重新加载规则文件（不重新加载server.yaml主配置），
+// 用于管理员在admin接口之外直接编辑了规则文件后手动触发生效
+func (a *AdminServer) handleRulesReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAdminError(w, http.StatusMethodNotAllowed, "不支持的方法: %s", r.Method)
+		return
+	}
+	if err := a.server.GetEngine().ReloadRules(); err != nil {
+		writeAdminError(w, http.StatusInternalServerError, "重新加载规则失败: %v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "reloaded"})
+}
+
+// dryRunRequest /admin/rules/dryrun的请求体：Content为待处理的原始内容，
+// Rules为空时使用当前生效的规则集，非空时以给定规则集做试运行，均不影响线上状态
+type dryRunRequest struct {
+	Content string        `json:"content"`
+	Rules   []config.Rule `json:"rules,omitempty"`
+}
+
+// dryRunResponse /admin/rules/dryrun的响应体
+type dryRunResponse struct {
+	Before       string   `json:"before"`
+	After        string   `json:"after"`
+	MatchedRules []string `json:"matched_rules"`
+}
+
+// handleRulesDryRun 用给定内容（和可选的候选规则集）跑一遍替换引擎，返回替换前后内容
+// 和命中的规则名，不修改线上引擎状态，便于在发布前预览规则效果
+func (a *AdminServer) handleRulesDryRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAdminError(w, http.StatusMethodNotAllowed, "不支持的方法: %s", r.Method)
+		return
+	}
+
+	var req dryRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAdminError(w, http.StatusBadRequest, "解析请求体失败: %v", err)
+		return
+	}
+
+	rules := req.Rules
+	if rules == nil {
+		rules = a.server.GetEngine().GetRules()
+	} else if err := config.ValidateRules(rules); err != nil {
+		writeAdminError(w, http.StatusUnprocessableEntity, "规则校验失败: %v", err)
+		return
+	}
+
+	dryEngine := replacer.NewEngineWithRules(rules)
+	after, err := dryEngine.Process(req.Content, nil)
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, "试运行失败: %v", err)
+		return
+	}
+
+	var matched []string
+	for _, rule := range rules {
+		if !rule.IsEnabled() {
+			continue
+		}
+		if rule.Match(nil, req.Content) {
+			matched = append(matched, rule.Name)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, dryRunResponse{Before: req.Content, After: after, MatchedRules: matched})
+}
+
+// handleReload 触发配置和规则重新加载
+func (a *AdminServer) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAdminError(w, http.StatusMethodNotAllowed, "不支持的方法: %s", r.Method)
+		return
+	}
+	if err := a.server.ReloadConfig(); err != nil {
+		writeAdminError(w, http.StatusInternalServerError, "重新加载失败: %v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "reloaded"})
+}
+
+// handleStats 返回服务器统计信息
+func (a *AdminServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.server.GetStats())
+}
+
+// handleHealth 返回健康检查结果
+func (a *AdminServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	health := a.server.HealthCheck()
+	status := http.StatusOK
+	if health["status"] != "healthy" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, health)
+}
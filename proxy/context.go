@@ -0,0 +1,16 @@
+package proxy
+
+import (
+	"content_replace/logger"
+	"context"
+)
+
+// withRequestID和requestIDFromContext委托给logger包的同名函数，使proxy包的请求处理链路
+// 和logger.With(ctx)共用同一个context键，不必在两个包里各维护一份
+func withRequestID(ctx context.Context, requestID string) context.Context {
+	return logger.WithRequestID(ctx, requestID)
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	return logger.RequestIDFromContext(ctx)
+}
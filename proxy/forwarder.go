@@ -2,16 +2,21 @@ package proxy
 
 import (
 	"bytes"
+	"compress/gzip"
 	"content_replace/config"
 	"content_replace/logger"
+	"content_replace/metrics"
+	"content_replace/replacer"
 	"context"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
-	
+
+	"github.com/andybalholm/brotli"
 	"github.com/fatih/color"
 )
 
@@ -36,6 +41,15 @@ type Forwarder struct {
 	httpClient     *http.Client
 	config         *config.Config
 	isMultiTarget  bool
+	streamEngine   *replacer.StreamEngine // 用于响应体流式替换，通过SetEngine注入
+}
+
+// SetEngine 注入替换引擎，使CopyResponse可以对响应体做流式规则替换
+func (f *Forwarder) SetEngine(engine *replacer.Engine) {
+	f.streamEngine = replacer.NewStreamEngine(engine)
+	if f.config.Target.StreamWindowSize > 0 {
+		f.streamEngine.SetWindowSize(f.config.Target.StreamWindowSize)
+	}
 }
 
 // NewForwarder 创建新的转发器
@@ -46,13 +60,18 @@ func NewForwarder(cfg *config.Config) (*Forwarder, error) {
 		return nil, fmt.Errorf("没有配置目标服务器URL")
 	}
 	
-	// 创建HTTP客户端
+	// 创建HTTP客户端。ForceAttemptHTTP2使得目标为https且通过ALPN协商支持HTTP/2时自动启用，
+	// 对纯HTTP/1.1的明文上游没有影响，因此始终开启是安全的。
+	// 注意：不对cleartext上游强制切到h2c-only的http2.Transport——那会要求所有上游都已
+	// 支持h2c，一旦上游只讲HTTP/1.1，整条转发链路就会直接失败（已通过实测确认），
+	// 与target.enable_upgrades"可选启用升级能力"的预期不符
 	httpClient := &http.Client{
 		Timeout: cfg.Target.Timeout,
 		Transport: &http.Transport{
 			MaxIdleConns:        100,
 			MaxIdleConnsPerHost: 10,
 			IdleConnTimeout:     90 * time.Second,
+			ForceAttemptHTTP2:   true,
 		},
 	}
 	
@@ -64,17 +83,21 @@ func NewForwarder(cfg *config.Config) (*Forwarder, error) {
 	// 判断是单目标还是多目标模式
 	if len(targetURLs) > 1 {
 		// 多目标模式：创建负载均衡器
-		lb, err := NewLoadBalancer(targetURLs)
+		lb, err := NewLoadBalancerWithStrategy(targetURLs, cfg.Target.GetStrategy())
 		if err != nil {
 			return nil, fmt.Errorf("创建负载均衡器失败: %v", err)
 		}
 		forwarder.loadBalancer = lb
 		forwarder.isMultiTarget = true
 		logger.Infof("转发器初始化: 多目标负载均衡模式，服务器数量 = %d，策略 = %s",
-			len(targetURLs), cfg.Target.Strategy)
+			len(targetURLs), cfg.Target.GetStrategy())
 		for i, urlStr := range targetURLs {
 			logger.Infof("  目标服务器[%d]: %s", i+1, urlStr)
 		}
+
+		if cfg.Target.HealthCheck.Enabled {
+			go forwarder.runHealthChecks()
+		}
 	} else {
 		// 单目标模式：直接使用单个URL
 		targetURLStr := targetURLs[0]
@@ -103,46 +126,261 @@ func NewForwarder(cfg *config.Config) (*Forwarder, error) {
 	return forwarder, nil
 }
 
-// ForwardRequest 转发HTTP请求
+// idempotentMethods 这些方法允许安全地重放到另一个目标：失败不会产生重复副作用
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// isRetryable 判断该请求方法是否允许参与重试/对冲：幂等方法总是允许，POST/PATCH等非幂等方法
+// 需要显式通过Retry.AllowNonIdempotent opt-in，因为body是从已缓冲的modifiedBody重新发送的，
+// 重试等于把同一个非幂等请求又打了一次
+func (f *Forwarder) isRetryable(method string) bool {
+	if idempotentMethods[strings.ToUpper(method)] {
+		return true
+	}
+	return f.config.Target.Retry.AllowNonIdempotent
+}
+
+// backoffWithJitter 计算第attempt次重试前的等待时长：基准按2^attempt指数增长并在cap处封顶，
+// 再叠加[0, d/2)的随机抖动（借鉴client-go URLBackoff/wait.Jitter的思路），避免大量请求在目标
+// 刚恢复时同时涌入造成二次过载
+func backoffWithJitter(attempt int, base, cap time.Duration) time.Duration {
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	if cap <= 0 {
+		cap = 2 * time.Second
+	}
+	d := base * time.Duration(int64(1)<<uint(attempt))
+	if d <= 0 || d > cap {
+		d = cap
+	}
+	jitter := time.Duration(rand.Int63n(int64(d)/2 + 1))
+	return d/2 + jitter
+}
+
+// forwardAttempt 一次转发尝试的结果
+type forwardAttempt struct {
+	resp    *http.Response
+	success bool
+	err     error
+}
+
+// ForwardRequest 转发HTTP请求。多目标模式下，遇到传输错误或5xx会按指数退避+抖动切换到
+// LoadBalancer的下一个目标重试；非幂等方法默认不重试（除非Retry.AllowNonIdempotent开启），
+// 避免重放已产生副作用的请求。Hedge.Enabled时，可重试的请求在主尝试超过Hedge.Delay仍未
+// 返回时会对另一个目标发起对冲请求，取先完成的一个
 func (f *Forwarder) ForwardRequest(req *http.Request, modifiedBody []byte) (*http.Response, error) {
-	startTime := time.Now()
-	
-	// 获取目标URL（支持负载均衡）
-	var baseURL *url.URL
-	if f.isMultiTarget {
-		// 多目标模式：从负载均衡器获取下一个目标
-		baseURL = f.loadBalancer.GetNext()
-		logger.Debugf("%s 选择目标服务器: %s", boldYellowF("[负载均衡]"), cyanF(baseURL.String()))
+	if !f.isMultiTarget {
+		resp, _, err := f.doForward(req, modifiedBody, f.targetURL)
+		return resp, err
+	}
+
+	maxAttempts := f.loadBalancer.GetTargetCount()
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	if f.config.Target.Retry.MaxRetries > 0 && f.config.Target.Retry.MaxRetries+1 < maxAttempts {
+		maxAttempts = f.config.Target.Retry.MaxRetries + 1
+	}
+
+	retryable := f.isRetryable(req.Method)
+	log := logger.With(req.Context())
+
+	var lastErr error
+	var lastResp *http.Response
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		baseURL := f.loadBalancer.GetNext(req.RemoteAddr)
+		if baseURL == nil {
+			if lastResp != nil {
+				return lastResp, nil
+			}
+			return nil, fmt.Errorf("没有可用的目标服务器")
+		}
+		log.Debugf("%s 选择目标服务器: %s (第%d次尝试)", boldYellowF("[负载均衡]"), cyanF(baseURL.String()), attempt+1)
+
+		var result forwardAttempt
+		if retryable && f.config.Target.Hedge.Enabled {
+			result = f.doForwardHedged(req, modifiedBody, baseURL)
+		} else {
+			result = f.runAttempt(req.Context(), req, modifiedBody, baseURL)
+		}
+
+		if result.success {
+			if lastResp != nil {
+				lastResp.Body.Close()
+			}
+			return result.resp, nil
+		}
+		lastErr = result.err
+		// result.resp非nil说明上游确实给出了响应（如5xx），只是doForward据状态码判定为不可重试；
+		// 保留这个真实响应，只有在所有尝试都连响应都拿不到时才对外合成错误，避免真实的5xx被吞掉、
+		// 客户端反而收到一个语义无关的"所有目标服务器均不可用"
+		if result.resp != nil {
+			if lastResp != nil {
+				lastResp.Body.Close()
+			}
+			lastResp = result.resp
+		}
+		log.Debugf("目标服务器 %s 请求失败，尝试下一个目标", redF(baseURL.String()))
+
+		if !retryable {
+			break
+		}
+		if attempt < maxAttempts-1 {
+			time.Sleep(backoffWithJitter(attempt, f.config.Target.Retry.BackoffBase, f.config.Target.Retry.BackoffCap))
+		}
+	}
+
+	if lastResp != nil {
+		return lastResp, nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("所有目标服务器均不可用")
+	}
+	return nil, lastErr
+}
+
+// runAttempt 对单个目标执行一次doForward，并统一记录in-flight释放、EWMA延迟和Prometheus指标，
+// 供普通重试路径和对冲路径共用
+func (f *Forwarder) runAttempt(ctx context.Context, req *http.Request, modifiedBody []byte, baseURL *url.URL) forwardAttempt {
+	attemptStart := time.Now()
+	resp, success, err := f.doForwardCtx(ctx, req, modifiedBody, baseURL)
+	f.loadBalancer.Release(baseURL, success)
+	f.loadBalancer.RecordLatency(baseURL, time.Since(attemptStart))
+	metrics.ObserveTargetLatency(baseURL.String(), time.Since(attemptStart).Seconds())
+	metrics.SetTargetInFlight(baseURL.String(), f.loadBalancer.InFlight(baseURL))
+	return forwardAttempt{resp: resp, success: success, err: err}
+}
+
+// namedAttempt 对冲场景下需要知道是哪个目标先返回，才能正确取消另一个
+type namedAttempt struct {
+	forwardAttempt
+	target *url.URL
+}
+
+// doForwardHedged 对primary发起一次尝试；若Hedge.Delay内未返回，再从负载均衡器取另一个
+// 目标并发发起第二次尝试，取先完成的一个，另一个通过context.CancelFunc取消（仍在后台运行时
+// 异步等待其结束并释放资源，避免响应体泄漏）。
+// 胜出一方的context绝不在这里立即cancel——调用方可能还没开始读取响应体（大body/流式/chunked
+// 响应尤其明显），过早cancel会导致下游读取中途收到"context canceled"、响应被截断。
+// 胜出方的cancel改为包到resp.Body.Close()里，调用方按正常流程读完并关闭body后才真正释放
+func (f *Forwarder) doForwardHedged(req *http.Request, modifiedBody []byte, primary *url.URL) forwardAttempt {
+	resultCh := make(chan namedAttempt, 2)
+
+	ctx1, cancel1 := context.WithCancel(req.Context())
+	go func() {
+		resultCh <- namedAttempt{f.runAttempt(ctx1, req, modifiedBody, primary), primary}
+	}()
+
+	var cancel2 context.CancelFunc
+	timer := time.NewTimer(f.config.Target.Hedge.Delay)
+	defer timer.Stop()
+
+	select {
+	case first := <-resultCh:
+		deferCancelUntilBodyClosed(first.resp, cancel1)
+		return first.forwardAttempt
+	case <-timer.C:
+		secondary := f.loadBalancer.GetNext(req.RemoteAddr)
+		if secondary == nil || secondary.String() == primary.String() {
+			first := <-resultCh
+			deferCancelUntilBodyClosed(first.resp, cancel1)
+			return first.forwardAttempt
+		}
+		var ctx2 context.Context
+		ctx2, cancel2 = context.WithCancel(req.Context())
+		logger.With(req.Context()).Debugf("%s 主请求 %s 超过%s未返回，对冲到 %s", boldYellowF("[对冲]"),
+			cyanF(primary.String()), f.config.Target.Hedge.Delay, cyanF(secondary.String()))
+		go func() {
+			resultCh <- namedAttempt{f.runAttempt(ctx2, req, modifiedBody, secondary), secondary}
+		}()
+	}
+
+	first := <-resultCh
+	var winnerCancel, loserCancel context.CancelFunc
+	if first.target.String() == primary.String() {
+		winnerCancel, loserCancel = cancel1, cancel2
 	} else {
-		// 单目标模式：使用固定目标
-		baseURL = f.targetURL
+		winnerCancel, loserCancel = cancel2, cancel1
 	}
-	
+	loserCancel()
+	deferCancelUntilBodyClosed(first.resp, winnerCancel)
+
+	go func() {
+		second := <-resultCh
+		if second.resp != nil {
+			second.resp.Body.Close()
+		}
+	}()
+
+	return first.forwardAttempt
+}
+
+// deferCancelUntilBodyClosed包装resp.Body，使cancel延迟到调用方正常Close()响应体时才触发，
+// 而不是在返回forwardAttempt时立即触发；resp为nil（本次尝试失败，没有body可读）时没有什么可等待，
+// 直接cancel避免context/goroutine泄漏
+func deferCancelUntilBodyClosed(resp *http.Response, cancel context.CancelFunc) {
+	if resp == nil || resp.Body == nil {
+		cancel()
+		return
+	}
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+}
+
+// cancelOnCloseBody 在Close()真正执行完毕后才调用cancel，保证响应体读取过程不会被context取消打断
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// doForward 向指定目标发送一次请求，返回响应、是否判定为成功（2xx/3xx/4xx视为成功，5xx/传输错误视为失败）
+func (f *Forwarder) doForward(req *http.Request, modifiedBody []byte, baseURL *url.URL) (*http.Response, bool, error) {
+	return f.doForwardCtx(req.Context(), req, modifiedBody, baseURL)
+}
+
+// doForwardCtx 同doForward，但请求绑定到调用方传入的ctx而不是req.Context()，
+// 使对冲场景下某一侧尝试可以通过取消ctx单独中止，不影响另一侧
+func (f *Forwarder) doForwardCtx(ctx context.Context, req *http.Request, modifiedBody []byte, baseURL *url.URL) (*http.Response, bool, error) {
+	startTime := time.Now()
+	log := logger.With(req.Context())
+
 	// 构建完整的目标URL
 	targetURL := f.buildTargetURL(baseURL, req.URL.Path, req.URL.RawQuery)
-	
-	logger.Debugf("转发请求到: %s %s", boldCyanF(req.Method), blueF(targetURL.String()))
-	
+
+	log.Debugf("转发请求到: %s %s", boldCyanF(req.Method), blueF(targetURL.String()))
+
 	// 创建新请求
-	targetReq, err := http.NewRequestWithContext(req.Context(), req.Method, targetURL.String(), bytes.NewReader(modifiedBody))
+	targetReq, err := http.NewRequestWithContext(ctx, req.Method, targetURL.String(), bytes.NewReader(modifiedBody))
 	if err != nil {
-		return nil, fmt.Errorf("创建目标请求失败: %v", err)
+		return nil, false, fmt.Errorf("创建目标请求失败: %v", err)
 	}
 
 	// 复制请求头
 	f.copyHeaders(req.Header, targetReq.Header)
-	
+
 	// 移除可能引起问题的头
 	f.removeProblematicHeaders(targetReq.Header)
-	
-	logger.Debugf("转发请求头数量: %s", cyanF(fmt.Sprintf("%d", len(targetReq.Header))))
-	
+
+	log.Debugf("转发请求头数量: %s", cyanF(fmt.Sprintf("%d", len(targetReq.Header))))
+
 	// 发送请求
 	resp, err := f.httpClient.Do(targetReq)
 	if err != nil {
-		return nil, fmt.Errorf("转发请求失败: %v", err)
+		metrics.UpstreamErrorsTotal.WithLabelValues(baseURL.String(), "transport_error").Inc()
+		return nil, false, fmt.Errorf("转发请求失败: %v", err)
 	}
-	
+
 	duration := time.Since(startTime)
 	var coloredStatus string
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
@@ -152,9 +390,13 @@ func (f *Forwarder) ForwardRequest(req *http.Request, modifiedBody []byte) (*htt
 	} else {
 		coloredStatus = yellowF(fmt.Sprintf("%d", resp.StatusCode))
 	}
-	logger.Debugf("转发请求完成，状态码: %s，耗时: %s", coloredStatus, blueF(duration.String()))
-	
-	return resp, nil
+	log.Debugf("转发请求完成，状态码: %s，耗时: %s", coloredStatus, blueF(duration.String()))
+
+	success := resp.StatusCode < http.StatusInternalServerError
+	if !success {
+		metrics.UpstreamErrorsTotal.WithLabelValues(baseURL.String(), fmt.Sprintf("status_%d", resp.StatusCode)).Inc()
+	}
+	return resp, success, nil
 }
 
 // buildTargetURL 构建目标URL
@@ -218,24 +460,37 @@ func (f *Forwarder) removeProblematicHeaders(headers http.Header) {
 	}
 }
 
-// CopyResponse 复制响应
-func (f *Forwarder) CopyResponse(w http.ResponseWriter, resp *http.Response) error {
+// CopyResponse 复制响应，若已通过SetEngine注入替换引擎，则对响应体做流式规则替换。
+// req为触发该响应的原始请求，用于构建规则作用范围匹配所需的EngineContext（method/path/host），
+// 以及判断该请求路径是否命中config.TargetConfig.Stream配置的免缓冲流式转发路径
+func (f *Forwarder) CopyResponse(w http.ResponseWriter, req *http.Request, resp *http.Response) error {
+	if f.streamEngine == nil {
+		return f.copyResponsePassthrough(w, resp)
+	}
+	if f.config.Target.ShouldStream(req.URL.Path) {
+		return f.copyResponseStreamed(w, req, resp)
+	}
+	return f.copyResponseWithReplacement(w, req, resp)
+}
+
+// copyResponsePassthrough 不做任何内容替换，原样转发响应体（兼容未注入引擎时的行为）
+func (f *Forwarder) copyResponsePassthrough(w http.ResponseWriter, resp *http.Response) error {
 	// 复制响应头
 	f.copyResponseHeaders(resp.Header, w.Header())
-	
+
 	// 复制状态码
 	w.WriteHeader(resp.StatusCode)
-	
+
 	// 复制响应体
 	if resp.Body != nil {
 		defer resp.Body.Close()
-		
+
 		_, err := io.Copy(w, resp.Body)
 		if err != nil {
 			return fmt.Errorf("复制响应体失败: %v", err)
 		}
 	}
-	
+
 	var coloredStatus string
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 		coloredStatus = greenF(fmt.Sprintf("%d", resp.StatusCode))
@@ -248,6 +503,149 @@ func (f *Forwarder) CopyResponse(w http.ResponseWriter, resp *http.Response) err
 	return nil
 }
 
+// copyResponseWithReplacement 解码响应体（如gzip/br压缩），流式通过规则引擎重写内容，
+// 再按原Content-Encoding重新编码后写回客户端，并重算Content-Length
+func (f *Forwarder) copyResponseWithReplacement(w http.ResponseWriter, req *http.Request, resp *http.Response) error {
+	if resp.Body != nil {
+		defer resp.Body.Close()
+	}
+
+	ctx := &config.EngineContext{
+		Method:      req.Method,
+		Path:        req.URL.Path,
+		Host:        req.Host,
+		Header:      map[string][]string(resp.Header),
+		ContentType: resp.Header.Get("Content-Type"),
+		Direction:   config.DirectionResponse,
+		RequestID:   requestIDFromContext(req.Context()),
+		StatusCode:  resp.StatusCode,
+	}
+
+	encoding := strings.ToLower(resp.Header.Get("Content-Encoding"))
+
+	decodedReader, err := decodeBody(resp.Body, encoding)
+	if err != nil {
+		logger.Error("解码响应体失败: %v", err)
+		return f.copyResponsePassthrough(w, resp)
+	}
+
+	var buf bytes.Buffer
+	encodedWriter, closeEncoder, err := encodeBody(&buf, encoding)
+	if err != nil {
+		logger.Error("编码响应体失败: %v", err)
+		return f.copyResponsePassthrough(w, resp)
+	}
+
+	if err := f.streamEngine.Stream(decodedReader, encodedWriter, ctx); err != nil {
+		return fmt.Errorf("流式替换响应体失败: %v", err)
+	}
+	if err := closeEncoder(); err != nil {
+		return fmt.Errorf("关闭响应体编码器失败: %v", err)
+	}
+
+	// 复制响应头，但Content-Length需要按重写后的实际长度重新计算
+	f.copyResponseHeaders(resp.Header, w.Header())
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", buf.Len()))
+
+	w.WriteHeader(resp.StatusCode)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("写入响应体失败: %v", err)
+	}
+
+	logger.With(req.Context()).Debugf("响应体流式替换完成，编码: %s，字节数: %d", yellowF(encoding), buf.Len())
+	return nil
+}
+
+// copyResponseStreamed 命中config.TargetConfig.Stream时使用的免缓冲流式路径：边从上游读取、
+// 边通过滑动窗口做规则替换、边写回客户端，不在内存里攒完整响应体，也不预先计算Content-Length，
+// 依赖Go http server在没有Content-Length时自动改用chunked Transfer-Encoding
+func (f *Forwarder) copyResponseStreamed(w http.ResponseWriter, req *http.Request, resp *http.Response) error {
+	if resp.Body != nil {
+		defer resp.Body.Close()
+	}
+
+	ctx := &config.EngineContext{
+		Method:      req.Method,
+		Path:        req.URL.Path,
+		Host:        req.Host,
+		Header:      map[string][]string(resp.Header),
+		ContentType: resp.Header.Get("Content-Type"),
+		Direction:   config.DirectionResponse,
+		RequestID:   requestIDFromContext(req.Context()),
+		StatusCode:  resp.StatusCode,
+	}
+
+	encoding := strings.ToLower(resp.Header.Get("Content-Encoding"))
+
+	decodedReader, err := decodeBody(resp.Body, encoding)
+	if err != nil {
+		logger.Error("解码响应体失败: %v", err)
+		return f.copyResponsePassthrough(w, resp)
+	}
+
+	f.copyResponseHeaders(resp.Header, w.Header())
+	w.Header().Del("Content-Length") // 边读边写，总长度未知，交由http server以chunked方式发出
+	w.WriteHeader(resp.StatusCode)
+
+	fw := &flushWriter{w: w}
+	encodedWriter, closeEncoder, err := encodeBody(fw, encoding)
+	if err != nil {
+		logger.Error("编码响应体失败: %v", err)
+		return f.copyResponsePassthrough(w, resp)
+	}
+
+	if err := f.streamEngine.Stream(decodedReader, encodedWriter, ctx); err != nil {
+		return fmt.Errorf("流式替换响应体失败: %v", err)
+	}
+	if err := closeEncoder(); err != nil {
+		return fmt.Errorf("关闭响应体编码器失败: %v", err)
+	}
+
+	logger.With(req.Context()).Debugf("响应体免缓冲流式替换完成，编码: %s", yellowF(encoding))
+	return nil
+}
+
+// flushWriter 每次Write后立即Flush底层http.ResponseWriter，使chunked响应的每个安全片段
+// 尽快发给客户端，而不是等到整个响应体写完才由Go runtime统一flush
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if flusher, ok := fw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
+
+// decodeBody 按Content-Encoding解码响应体，非gzip/br时原样返回
+func decodeBody(body io.Reader, encoding string) (io.Reader, error) {
+	switch encoding {
+	case "gzip":
+		return gzip.NewReader(body)
+	case "br":
+		return brotli.NewReader(body), nil
+	default:
+		return body, nil
+	}
+}
+
+// encodeBody 按Content-Encoding重新编码，返回写入器和收尾函数（用于flush/close压缩流）
+func encodeBody(w io.Writer, encoding string) (io.Writer, func() error, error) {
+	switch encoding {
+	case "gzip":
+		gz := gzip.NewWriter(w)
+		return gz, gz.Close, nil
+	case "br":
+		br := brotli.NewWriter(w)
+		return br, br.Close, nil
+	default:
+		return w, func() error { return nil }, nil
+	}
+}
+
 // copyResponseHeaders 复制响应头
 func (f *Forwarder) copyResponseHeaders(src, dst http.Header) {
 	for key, values := range src {
@@ -286,7 +684,11 @@ func (f *Forwarder) shouldSkipResponseHeader(key string) bool {
 func (f *Forwarder) GetTargetURL() *url.URL {
 	if f.isMultiTarget && f.loadBalancer != nil {
 		// 多目标模式：返回第一个目标
-		return f.loadBalancer.targets[0]
+		targets := f.loadBalancer.Targets()
+		if len(targets) > 0 {
+			return targets[0]
+		}
+		return nil
 	}
 	return f.targetURL
 }
@@ -301,45 +703,44 @@ func (f *Forwarder) SetTimeout(timeout time.Duration) {
 	f.httpClient.Timeout = timeout
 }
 
-// IsHealthy 检查目标服务器健康状态
+// IsHealthy 检查目标服务器健康状态（多目标模式下检查第一个目标，作为整体健康状态的代表）
 func (f *Forwarder) IsHealthy(ctx context.Context) bool {
-	var targetURL *url.URL
-	
-	if f.isMultiTarget && f.loadBalancer != nil {
-		// 多目标模式：检查第一个目标
-		targetURL = f.loadBalancer.targets[0]
-	} else {
-		targetURL = f.targetURL
-	}
-	
+	targetURL := f.GetTargetURL()
 	if targetURL == nil {
 		return false
 	}
-	
+	return f.probeHealth(ctx, targetURL)
+}
+
+// probeHealth 对单个目标发起一次健康检查请求，探测路径取自配置（HealthCheckConfig.Path）
+func (f *Forwarder) probeHealth(ctx context.Context, targetURL *url.URL) bool {
+	healthPath := f.config.Target.HealthCheck.Path
+	if healthPath == "" {
+		healthPath = "/health"
+	}
+
 	healthURL := *targetURL
-	healthURL.Path = "/health"
-	
+	healthURL.Path = healthPath
+
 	req, err := http.NewRequestWithContext(ctx, "GET", healthURL.String(), nil)
 	if err != nil {
 		logger.Debugf("创建健康检查请求失败: %s", redF(err.Error()))
 		return false
 	}
-	
+
 	resp, err := f.httpClient.Do(req)
 	if err != nil {
 		logger.Debugf("健康检查请求失败: %s", redF(err.Error()))
 		return false
 	}
 	defer resp.Body.Close()
-	
+
 	isHealthy := resp.StatusCode >= 200 && resp.StatusCode < 300
-	var statusText, statusColor string
+	var statusColor string
 	if isHealthy {
-		statusText = "健康"
-		statusColor = greenF(statusText)
+		statusColor = greenF("健康")
 	} else {
-		statusText = "不健康"
-		statusColor = redF(statusText)
+		statusColor = redF("不健康")
 	}
 	var coloredStatusCode string
 	if isHealthy {
@@ -347,11 +748,71 @@ func (f *Forwarder) IsHealthy(ctx context.Context) bool {
 	} else {
 		coloredStatusCode = redF(fmt.Sprintf("%d", resp.StatusCode))
 	}
-	logger.Debugf("健康检查结果: %s (状态码: %s)", statusColor, coloredStatusCode)
-	
+	logger.Debugf("健康检查结果 [%s]: %s (状态码: %s)", targetURL.String(), statusColor, coloredStatusCode)
+
 	return isHealthy
 }
 
+// runHealthChecks 后台周期性探测多目标模式下的每个目标，并把结果同步到负载均衡器，
+// 使GetNext()不会把流量导向已探测为不健康的目标。每个目标的探测节奏由RecordHealthCheck
+// 驱动的指数退避独立控制，因此这里用比baseInterval更细的tick去轮询"是否到期"，而不是
+// 每个tick都探测全部目标
+func (f *Forwarder) runHealthChecks() {
+	baseInterval := f.config.Target.HealthCheck.Interval
+	if baseInterval <= 0 {
+		baseInterval = 30 * time.Second
+	}
+	timeout := f.config.Target.HealthCheck.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	maxBackoff := f.config.Target.HealthCheck.MaxBackoff
+	failureThreshold := f.config.Target.HealthCheck.FailureThreshold
+	healthyThreshold := f.config.Target.HealthCheck.HealthyThreshold
+
+	tick := baseInterval
+	if tick > time.Second {
+		tick = time.Second
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, target := range f.loadBalancer.Targets() {
+			if !f.loadBalancer.DueForHealthCheck(target) {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			healthy := f.probeHealth(ctx, target)
+			cancel()
+			transition := f.loadBalancer.RecordHealthCheck(target, healthy, baseInterval, maxBackoff, failureThreshold, healthyThreshold)
+			metrics.SetTargetHealthy(target.String(), transition.Healthy)
+			if transition.Changed {
+				logger.LogStructured("", "target_health_transition", map[string]interface{}{
+					"target":  target.String(),
+					"healthy": transition.Healthy,
+				})
+			}
+		}
+	}
+}
+
+// HealthStatuses 返回每个目标的健康探测状态，供/proxy/health端点展示；单目标模式下返回
+// 一个基于实时探测的条目（单目标模式没有后台健康检查goroutine和熔断器）
+func (f *Forwarder) HealthStatuses() []map[string]interface{} {
+	if f.isMultiTarget {
+		return f.loadBalancer.HealthStatuses()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), f.config.Target.HealthCheck.Timeout)
+	defer cancel()
+	return []map[string]interface{}{
+		{
+			"target":  f.targetURL.String(),
+			"healthy": f.probeHealth(ctx, f.targetURL),
+		},
+	}
+}
+
 // GetStats 获取转发器统计信息
 func (f *Forwarder) GetStats() map[string]interface{} {
 	stats := map[string]interface{}{
@@ -362,7 +823,8 @@ func (f *Forwarder) GetStats() map[string]interface{} {
 	if f.isMultiTarget && f.loadBalancer != nil {
 		stats["mode"] = "load_balancing"
 		stats["target_count"] = f.loadBalancer.GetTargetCount()
-		stats["strategy"] = f.config.Target.Strategy
+		stats["strategy"] = f.config.Target.GetStrategy()
+		stats["targets"] = f.loadBalancer.Stats()
 	} else if f.targetURL != nil {
 		stats["mode"] = "single_target"
 		stats["target_url"] = f.targetURL.String()
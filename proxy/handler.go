@@ -3,12 +3,15 @@ package proxy
 import (
 	"content_replace/config"
 	"content_replace/logger"
+	"content_replace/metrics"
 	"content_replace/replacer"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -35,7 +38,27 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	requestID := h.generateRequestID()
 	
 	logger.LogRequestStart(requestID, req.Method, req.URL.Path)
-	
+
+	// /proxy/health 由代理自身直接应答，返回每个目标的健康探测状态，不转发给上游
+	if req.URL.Path == "/proxy/health" {
+		h.handleProxyHealth(w)
+		return
+	}
+
+	// cfg.Server.MetricsPath非空时，在数据面监听端口上直接暴露Prometheus抓取端点，
+	// 作为/admin/metrics（管理面，需cfg.Admin.Enabled）之外的可选opt-in入口
+	if h.config.Server.MetricsPath != "" && req.URL.Path == h.config.Server.MetricsPath {
+		metrics.Handler().ServeHTTP(w, req)
+		return
+	}
+
+	// WebSocket升级请求走独立的帧级转发路径，不能按普通请求/响应缓冲处理；
+	// 需要target.enable_upgrades显式开启，否则按普通请求转发（Connection/Upgrade头被剥离，升级会失败）
+	if h.config.Target.EnableUpgrades && isWebSocketUpgrade(req) {
+		h.handleUpgrade(w, req)
+		return
+	}
+
 	// 记录原始请求
 	if h.config.ShouldShowOriginal() {
 		headers := make(map[string][]string)
@@ -64,10 +87,21 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	
+	// 构建规则作用范围匹配所需的请求上下文，RequestID用于跨Engine.Process/Forwarder关联同一次请求的日志
+	requestCtx := &config.EngineContext{
+		Method:      req.Method,
+		Path:        req.URL.Path,
+		Host:        req.Host,
+		Header:      map[string][]string(req.Header),
+		ContentType: req.Header.Get("Content-Type"),
+		Direction:   config.DirectionRequest,
+		RequestID:   requestID,
+	}
+
 	// 如果有内容，进行替换处理
 	var modifiedBody []byte
 	if len(body) > 0 {
-		modifiedBodyStr, err := h.engine.Process(body)
+		modifiedBodyStr, err := h.engine.Process(body, requestCtx)
 		if err != nil {
 			logger.Error("内容替换失败: %v", err)
 			http.Error(w, "内容替换失败", http.StatusInternalServerError)
@@ -87,23 +121,63 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		modifiedBody = []byte(body)
 	}
 	
+	// requestID写入context，使ForwardRequest/CopyResponse等下游调用在不改签名的情况下也能拿到同一个关联ID
+	req = req.WithContext(withRequestID(req.Context(), requestID))
+
 	// 转发请求
 	resp, err := h.forwarder.ForwardRequest(req, modifiedBody)
 	if err != nil {
 		logger.Error("转发失败: %v", err)
 		http.Error(w, "转发失败", http.StatusBadGateway)
+		metrics.RequestsTotal.WithLabelValues(req.Method, "502", "false").Inc()
 		return
 	}
 	defer resp.Body.Close()
-	
+
 	// 复制响应
-	if err := h.forwarder.CopyResponse(w, resp); err != nil {
+	if err := h.forwarder.CopyResponse(w, req, resp); err != nil {
 		logger.Error("复制响应失败: %v", err)
 		return
 	}
-	
+
 	duration := time.Since(startTime)
 	logger.LogRequestEnd(requestID, resp.StatusCode, duration)
+
+	ruleMatched := strconv.FormatBool(len(body) > 0 && len(modifiedBody) > 0 && string(modifiedBody) != body)
+	metrics.RequestsTotal.WithLabelValues(req.Method, strconv.Itoa(resp.StatusCode), ruleMatched).Inc()
+	metrics.RequestDuration.WithLabelValues(req.Method).Observe(duration.Seconds())
+	logger.LogStructured(requestID, "request_completed", map[string]interface{}{
+		"method":       req.Method,
+		"path":         req.URL.Path,
+		"status":       resp.StatusCode,
+		"duration_ms":  duration.Milliseconds(),
+		"rule_matched": ruleMatched,
+	})
+}
+
+// handleProxyHealth 返回每个目标服务器的健康探测状态，供运维监控负载均衡池的实时健康面，
+// 与/admin/health（代表本代理进程整体健康）是互补而非替代关系
+func (h *Handler) handleProxyHealth(w http.ResponseWriter) {
+	statuses := h.forwarder.HealthStatuses()
+
+	allHealthy := true
+	for _, s := range statuses {
+		if healthy, ok := s["healthy"].(bool); ok && !healthy {
+			allHealthy = false
+			break
+		}
+	}
+
+	status := http.StatusOK
+	if !allHealthy {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"targets": statuses}); err != nil {
+		logger.Error("序列化/proxy/health响应失败: %v", err)
+	}
 }
 
 // readRequestBody 读取请求体
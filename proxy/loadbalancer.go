@@ -1,61 +1,573 @@
 package proxy
 
 import (
+	"hash/fnv"
+	"math/rand"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// LoadBalancer 简单的负载均衡器
+// LBStrategy 负载均衡策略枚举
+type LBStrategy string
+
+const (
+	StrategyRoundRobin LBStrategy = "round_robin"
+	StrategyWeighted    LBStrategy = "weighted"
+	StrategyLeastConn   LBStrategy = "least_conn"
+	StrategyIPHash      LBStrategy = "ip_hash"
+	StrategyP2CEWMA     LBStrategy = "p2c_ewma" // Power of Two Choices，按EWMA延迟*(in-flight+1)打分二选一
+)
+
+// ewmaDecay 是EWMA延迟的衰减系数：新样本权重(1-ewmaDecay)，历史权重ewmaDecay，
+// 数值越接近1对瞬时抖动越不敏感
+const ewmaDecay = 0.9
+
+// 熔断默认参数：连续失败次数达到阈值后熔断器打开，冷却结束后进入半开状态试探一次
+const (
+	defaultFailureThreshold = 5
+	defaultCooldown         = 30 * time.Second
+)
+
+// 健康检查退避默认参数：RecordHealthCheck未显式传入failureThreshold时的兜底值，
+// 以及退避倍数2^n的指数上限（2^6=64倍baseInterval，再往上会被maxBackoff封顶，继续增长已无意义）
+const (
+	defaultHealthFailureThreshold = 3
+	maxHealthBackoffShift         = 6
+)
+
+// breakerState 熔断器状态
+type breakerState int32
+
+const (
+	breakerClosed   breakerState = iota // 正常
+	breakerOpen                         // 已熔断，跳过该目标
+	breakerHalfOpen                     // 冷却结束，放行一次探测请求
+)
+
+// circuitBreaker 单个目标的熔断器，连续失败达到阈值后打开，冷却后进入半开状态试探
+type circuitBreaker struct {
+	state            int32 // breakerState，原子读写
+	consecutiveFails int32
+	threshold        int32
+	cooldown         time.Duration
+	openedAt         int64 // UnixNano，熔断打开的时间
+}
+
+func newCircuitBreaker() *circuitBreaker {
+	return &circuitBreaker{threshold: defaultFailureThreshold, cooldown: defaultCooldown}
+}
+
+// Allow 判断当前是否允许向该目标发请求
+func (cb *circuitBreaker) Allow() bool {
+	switch breakerState(atomic.LoadInt32(&cb.state)) {
+	case breakerClosed:
+		return true
+	case breakerHalfOpen:
+		return true
+	case breakerOpen:
+		openedAt := time.Unix(0, atomic.LoadInt64(&cb.openedAt))
+		if time.Since(openedAt) >= cb.cooldown {
+			atomic.StoreInt32(&cb.state, int32(breakerHalfOpen))
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// RecordResult 记录一次请求的结果，驱动熔断器状态迁移
+func (cb *circuitBreaker) RecordResult(success bool) {
+	if success {
+		atomic.StoreInt32(&cb.consecutiveFails, 0)
+		atomic.StoreInt32(&cb.state, int32(breakerClosed))
+		return
+	}
+
+	fails := atomic.AddInt32(&cb.consecutiveFails, 1)
+	if fails >= cb.threshold {
+		atomic.StoreInt64(&cb.openedAt, time.Now().UnixNano())
+		atomic.StoreInt32(&cb.state, int32(breakerOpen))
+	}
+}
+
+// IsOpen 熔断器当前是否处于打开状态（供统计展示使用）
+func (cb *circuitBreaker) IsOpen() bool {
+	return breakerState(atomic.LoadInt32(&cb.state)) == breakerOpen
+}
+
+// lbTarget 负载均衡器管理的单个目标服务器
+type lbTarget struct {
+	url      *url.URL
+	weight   int
+	metadata map[string]string // 来自URLs条目中"url|weight|k1=v1;k2=v2"的第三段，供Stats/未来策略使用
+	inFlight int64             // 原子计数，当前正在处理的请求数
+	healthy  int32             // 原子bool：1健康，0不健康
+	breaker  *circuitBreaker
+	ewmaNs   int64 // 原子计数，请求耗时的指数加权移动平均（纳秒），供p2c_ewma策略打分
+
+	healthFails    int32 // 原子计数，连续健康检查失败次数，驱动指数退避
+	healthSuccesses int32 // 原子计数，目标处于不健康状态时的连续探测成功次数，驱动HealthyThreshold判断
+	nextCheckAt    int64 // 原子UnixNano，下一次允许探测该目标健康状态的时间
+}
+
+func newLBTarget(u *url.URL, weight int, metadata map[string]string) *lbTarget {
+	if weight <= 0 {
+		weight = 1
+	}
+	return &lbTarget{url: u, weight: weight, metadata: metadata, healthy: 1, breaker: newCircuitBreaker()}
+}
+
+func (t *lbTarget) isAvailable() bool {
+	return atomic.LoadInt32(&t.healthy) == 1 && t.breaker.Allow()
+}
+
+func (t *lbTarget) setHealthy(healthy bool) {
+	if healthy {
+		atomic.StoreInt32(&t.healthy, 1)
+	} else {
+		atomic.StoreInt32(&t.healthy, 0)
+	}
+}
+
+// LoadBalancer 负载均衡器，支持round_robin/weighted/least_conn/ip_hash策略，
+// 并对每个目标维护健康状态与熔断器，GetNext永远不会返回不健康或已熔断的目标（除非全部不可用）
 type LoadBalancer struct {
-	targets      []*url.URL
+	targets      []*lbTarget
+	strategy     LBStrategy
 	currentIndex int
+	weightCursor int // weighted策略下基于平滑加权轮询的游标
 	mutex        sync.Mutex
 }
 
-// NewLoadBalancer 创建新的负载均衡器
+// NewLoadBalancer 创建新的负载均衡器。targetURLs中每一项可以是"url"或"url|weight"
 func NewLoadBalancer(targetURLs []string) (*LoadBalancer, error) {
+	return NewLoadBalancerWithStrategy(targetURLs, string(StrategyRoundRobin))
+}
+
+// NewLoadBalancerWithStrategy 创建指定策略的负载均衡器
+func NewLoadBalancerWithStrategy(targetURLs []string, strategy string) (*LoadBalancer, error) {
 	if len(targetURLs) == 0 {
 		return nil, nil // 如果没有URL，返回nil（单目标模式）
 	}
-	
-	// 解析所有URL
-	targets := make([]*url.URL, 0, len(targetURLs))
-	for _, urlStr := range targetURLs {
+
+	targets := make([]*lbTarget, 0, len(targetURLs))
+	for _, raw := range targetURLs {
+		urlStr, weight, metadata := parseTargetSpec(raw)
 		parsedURL, err := url.Parse(urlStr)
 		if err != nil {
 			return nil, err
 		}
-		targets = append(targets, parsedURL)
+		targets = append(targets, newLBTarget(parsedURL, weight, metadata))
 	}
-	
+
+	lbStrategy := LBStrategy(strategy)
+	switch lbStrategy {
+	case StrategyRoundRobin, StrategyWeighted, StrategyLeastConn, StrategyIPHash, StrategyP2CEWMA:
+	default:
+		lbStrategy = StrategyRoundRobin
+	}
+
 	return &LoadBalancer{
-		targets:      targets,
-		currentIndex: 0,
+		targets:  targets,
+		strategy: lbStrategy,
 	}, nil
 }
 
-// GetNext 获取下一个目标服务器（轮询）
-func (lb *LoadBalancer) GetNext() *url.URL {
+// parseTargetSpec 解析TargetConfig.URLs中的一条目标，格式为"url"、"url|weight"或
+// "url|weight|k1=v1;k2=v2"，weight缺省为1，metadata缺省为空map
+func parseTargetSpec(raw string) (string, int, map[string]string) {
+	parts := strings.SplitN(raw, "|", 3)
+
+	urlStr := strings.TrimSpace(parts[0])
+	weight := 1
+	if len(parts) >= 2 {
+		if w, err := strconv.Atoi(strings.TrimSpace(parts[1])); err == nil && w > 0 {
+			weight = w
+		}
+	}
+
+	metadata := map[string]string{}
+	if len(parts) == 3 {
+		for _, pair := range strings.Split(parts[2], ";") {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			key := strings.TrimSpace(kv[0])
+			if key == "" {
+				continue
+			}
+			metadata[key] = strings.TrimSpace(kv[1])
+		}
+	}
+
+	return urlStr, weight, metadata
+}
+
+// GetNext 按配置的策略获取下一个可用目标服务器；clientKey仅在ip_hash策略下使用（通常是客户端IP）
+func (lb *LoadBalancer) GetNext(clientKey string) *url.URL {
+	target := lb.pickTarget(clientKey)
+	if target == nil {
+		return nil
+	}
+	atomic.AddInt64(&target.inFlight, 1)
+	return target.url
+}
+
+// Release 在请求结束后调用，释放in-flight计数并把结果反馈给对应目标的熔断器
+func (lb *LoadBalancer) Release(target *url.URL, success bool) {
+	if lb == nil || target == nil {
+		return
+	}
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	for _, t := range lb.targets {
+		if t.url.String() == target.String() {
+			atomic.AddInt64(&t.inFlight, -1)
+			t.breaker.RecordResult(success)
+			return
+		}
+	}
+}
+
+// InFlight 返回某个目标当前的in-flight请求数，供指标上报使用
+func (lb *LoadBalancer) InFlight(target *url.URL) int64 {
+	if lb == nil || target == nil {
+		return 0
+	}
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	for _, t := range lb.targets {
+		if t.url.String() == target.String() {
+			return atomic.LoadInt64(&t.inFlight)
+		}
+	}
+	return 0
+}
+
+// RecordLatency 记录一次请求的耗时，更新对应目标的EWMA，供p2c_ewma策略打分
+func (lb *LoadBalancer) RecordLatency(target *url.URL, latency time.Duration) {
+	if lb == nil || target == nil {
+		return
+	}
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	for _, t := range lb.targets {
+		if t.url.String() == target.String() {
+			updateEWMA(&t.ewmaNs, latency.Nanoseconds())
+			return
+		}
+	}
+}
+
+// updateEWMA 以CAS方式原子更新指数加权移动平均，首个样本直接作为初始值
+func updateEWMA(addr *int64, sample int64) {
+	for {
+		old := atomic.LoadInt64(addr)
+		next := sample
+		if old != 0 {
+			next = int64(float64(old)*ewmaDecay + float64(sample)*(1-ewmaDecay))
+		}
+		if atomic.CompareAndSwapInt64(addr, old, next) {
+			return
+		}
+	}
+}
+
+// DueForHealthCheck 判断是否到了该对某个目标发起健康探测的时间，由RecordHealthCheck驱动的
+// 指数退避计时器控制；找不到目标时默认视为到期（保守地允许探测）
+func (lb *LoadBalancer) DueForHealthCheck(target *url.URL) bool {
+	if lb == nil || target == nil {
+		return true
+	}
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	for _, t := range lb.targets {
+		if t.url.String() == target.String() {
+			return time.Now().UnixNano() >= atomic.LoadInt64(&t.nextCheckAt)
+		}
+	}
+	return true
+}
+
+// HealthTransition 描述一次健康探测后目标的健康状态是否发生了变化，供调用方只在真正
+// 发生翻转时打结构化日志，而不是每次探测都记录一条
+type HealthTransition struct {
+	Target    *url.URL
+	Changed   bool
+	Healthy   bool
+}
+
+// RecordHealthCheck 记录一次健康探测结果并安排下一次探测时间：探测成功则按baseInterval重置节奏，
+// 但若目标当前处于不健康状态，需要连续healthyThreshold次成功才恢复为健康（类似k8s readiness探针）；
+// 连续失败达到failureThreshold才标记为不健康，且探测间隔按baseInterval*2^n指数退避，直到maxBackoff封顶，
+// 避免对已经挂掉的目标仍然按基准间隔高频重试。返回值供调用方判断健康状态是否发生了翻转
+func (lb *LoadBalancer) RecordHealthCheck(target *url.URL, healthy bool, baseInterval, maxBackoff time.Duration, failureThreshold, healthyThreshold int) HealthTransition {
+	if lb == nil || target == nil {
+		return HealthTransition{Target: target}
+	}
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	for _, t := range lb.targets {
+		if t.url.String() != target.String() {
+			continue
+		}
+
+		wasHealthy := atomic.LoadInt32(&t.healthy) == 1
+
+		if healthy {
+			atomic.StoreInt32(&t.healthFails, 0)
+			atomic.StoreInt64(&t.nextCheckAt, time.Now().Add(baseInterval).UnixNano())
+
+			if wasHealthy {
+				return HealthTransition{Target: target, Healthy: true}
+			}
+
+			if healthyThreshold <= 0 {
+				healthyThreshold = 1
+			}
+			successes := atomic.AddInt32(&t.healthSuccesses, 1)
+			if int(successes) < healthyThreshold {
+				return HealthTransition{Target: target, Healthy: false}
+			}
+			atomic.StoreInt32(&t.healthSuccesses, 0)
+			t.setHealthy(true)
+			return HealthTransition{Target: target, Changed: true, Healthy: true}
+		}
+
+		atomic.StoreInt32(&t.healthSuccesses, 0)
+		fails := atomic.AddInt32(&t.healthFails, 1)
+		if failureThreshold <= 0 {
+			failureThreshold = defaultHealthFailureThreshold
+		}
+		nowUnhealthy := wasHealthy && int(fails) >= failureThreshold
+		if nowUnhealthy {
+			t.setHealthy(false)
+		}
+
+		backoffExp := int(fails) - failureThreshold + 1
+		if backoffExp < 0 {
+			backoffExp = 0
+		}
+		if backoffExp > maxHealthBackoffShift {
+			backoffExp = maxHealthBackoffShift
+		}
+		backoff := baseInterval * time.Duration(int64(1)<<uint(backoffExp))
+		if maxBackoff > 0 && backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		atomic.StoreInt64(&t.nextCheckAt, time.Now().Add(backoff).UnixNano())
+		return HealthTransition{Target: target, Changed: nowUnhealthy, Healthy: atomic.LoadInt32(&t.healthy) == 1}
+	}
+	return HealthTransition{Target: target}
+}
+
+// pickTarget 根据策略选择一个可用目标，全部不可用时退化为忽略健康状态（避免雪崩式全量503）
+func (lb *LoadBalancer) pickTarget(clientKey string) *lbTarget {
 	if lb == nil || len(lb.targets) == 0 {
 		return nil
 	}
-	
+
 	lb.mutex.Lock()
 	defer lb.mutex.Unlock()
-	
-	// 获取当前索引的目标
-	target := lb.targets[lb.currentIndex]
-	
-	// 更新索引，轮询到下一个
-	lb.currentIndex = (lb.currentIndex + 1) % len(lb.targets)
-	
+
+	available := lb.availableTargetsLocked()
+	if len(available) == 0 {
+		// 所有目标都不健康/已熔断时，宁可尝试第一个也不要直接失败
+		available = lb.targets
+	}
+
+	switch lb.strategy {
+	case StrategyWeighted:
+		return lb.pickWeightedLocked(available)
+	case StrategyLeastConn:
+		return pickLeastConn(available)
+	case StrategyIPHash:
+		return pickIPHash(available, clientKey)
+	case StrategyP2CEWMA:
+		return pickP2CEWMA(available)
+	default:
+		return lb.pickRoundRobinLocked(available)
+	}
+}
+
+func (lb *LoadBalancer) availableTargetsLocked() []*lbTarget {
+	available := make([]*lbTarget, 0, len(lb.targets))
+	for _, t := range lb.targets {
+		if t.isAvailable() {
+			available = append(available, t)
+		}
+	}
+	return available
+}
+
+func (lb *LoadBalancer) pickRoundRobinLocked(available []*lbTarget) *lbTarget {
+	target := available[lb.currentIndex%len(available)]
+	lb.currentIndex = (lb.currentIndex + 1) % len(available)
 	return target
 }
 
+// pickWeightedLocked 平滑加权轮询（Nginx SWRR的简化版）
+func (lb *LoadBalancer) pickWeightedLocked(available []*lbTarget) *lbTarget {
+	totalWeight := 0
+	for _, t := range available {
+		totalWeight += t.weight
+	}
+	if totalWeight == 0 {
+		return lb.pickRoundRobinLocked(available)
+	}
+
+	lb.weightCursor = (lb.weightCursor + 1) % totalWeight
+	cursor := lb.weightCursor
+	for _, t := range available {
+		if cursor < t.weight {
+			return t
+		}
+		cursor -= t.weight
+	}
+	return available[0]
+}
+
+func pickLeastConn(available []*lbTarget) *lbTarget {
+	best := available[0]
+	bestLoad := atomic.LoadInt64(&best.inFlight)
+	for _, t := range available[1:] {
+		load := atomic.LoadInt64(&t.inFlight)
+		if load < bestLoad {
+			best, bestLoad = t, load
+		}
+	}
+	return best
+}
+
+// pickP2CEWMA 随机取两个候选，选负载分数较低的一个（Power of Two Choices），
+// 分数为EWMA延迟*(in-flight+1)，兼顾"历史响应快"和"当前不拥堵"两个维度
+func pickP2CEWMA(available []*lbTarget) *lbTarget {
+	if len(available) == 1 {
+		return available[0]
+	}
+
+	i := rand.Intn(len(available))
+	j := rand.Intn(len(available) - 1)
+	if j >= i {
+		j++
+	}
+
+	a, b := available[i], available[j]
+	if ewmaLoadScore(a) <= ewmaLoadScore(b) {
+		return a
+	}
+	return b
+}
+
+// ewmaLoadScore 返回用于p2c_ewma比较的负载分数，越小越优先
+func ewmaLoadScore(t *lbTarget) int64 {
+	ewma := atomic.LoadInt64(&t.ewmaNs)
+	if ewma == 0 {
+		// 还没有样本时不应被当作0分（最优）而被过度选中，给一个中性初始值
+		ewma = int64(time.Millisecond)
+	}
+	inFlight := atomic.LoadInt64(&t.inFlight)
+	return ewma * (inFlight + 1)
+}
+
+func pickIPHash(available []*lbTarget, clientKey string) *lbTarget {
+	if clientKey == "" {
+		return available[0]
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(clientKey))
+	idx := int(h.Sum32()) % len(available)
+	if idx < 0 {
+		idx += len(available)
+	}
+	return available[idx]
+}
+
 // GetTargetCount 获取目标服务器数量
 func (lb *LoadBalancer) GetTargetCount() int {
 	if lb == nil {
 		return 0
 	}
 	return len(lb.targets)
-}
\ No newline at end of file
+}
+
+// SetHealthy 设置某个目标的健康状态，供健康探测goroutine调用
+func (lb *LoadBalancer) SetHealthy(target *url.URL, healthy bool) {
+	if lb == nil || target == nil {
+		return
+	}
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	for _, t := range lb.targets {
+		if t.url.String() == target.String() {
+			t.setHealthy(healthy)
+			return
+		}
+	}
+}
+
+// Targets 返回所有目标URL（用于健康探测遍历）
+func (lb *LoadBalancer) Targets() []*url.URL {
+	if lb == nil {
+		return nil
+	}
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	urls := make([]*url.URL, 0, len(lb.targets))
+	for _, t := range lb.targets {
+		urls = append(urls, t.url)
+	}
+	return urls
+}
+
+// HealthStatuses 返回每个目标的健康探测状态，供/proxy/health端点展示
+func (lb *LoadBalancer) HealthStatuses() []map[string]interface{} {
+	if lb == nil {
+		return nil
+	}
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	statuses := make([]map[string]interface{}, 0, len(lb.targets))
+	for _, t := range lb.targets {
+		statuses = append(statuses, map[string]interface{}{
+			"target":       t.url.String(),
+			"healthy":      atomic.LoadInt32(&t.healthy) == 1,
+			"breaker_open": t.breaker.IsOpen(),
+			"health_fails": atomic.LoadInt32(&t.healthFails),
+		})
+	}
+	return statuses
+}
+
+// Stats 返回每个目标的负载均衡统计信息，供Server.GetStats展示熔断器状态
+func (lb *LoadBalancer) Stats() []map[string]interface{} {
+	if lb == nil {
+		return nil
+	}
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	stats := make([]map[string]interface{}, 0, len(lb.targets))
+	for _, t := range lb.targets {
+		entry := map[string]interface{}{
+			"target":        t.url.String(),
+			"weight":        t.weight,
+			"in_flight":      atomic.LoadInt64(&t.inFlight),
+			"healthy":       atomic.LoadInt32(&t.healthy) == 1,
+			"breaker_open":  t.breaker.IsOpen(),
+			"ewma_ms":       float64(atomic.LoadInt64(&t.ewmaNs)) / float64(time.Millisecond),
+			"health_fails":  atomic.LoadInt32(&t.healthFails),
+		}
+		if len(t.metadata) > 0 {
+			entry["metadata"] = t.metadata
+		}
+		stats = append(stats, entry)
+	}
+	return stats
+}
@@ -3,12 +3,17 @@ package proxy
 import (
 	"content_replace/config"
 	"content_replace/logger"
+	"content_replace/metrics"
 	"content_replace/replacer"
+	"content_replace/watcher"
 	"context"
 	"fmt"
 	"net/http"
 	"sync"
 	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // Server HTTP代理服务器
@@ -18,11 +23,17 @@ type Server struct {
 	engine    *replacer.Engine
 	forwarder *Forwarder
 	handler   *Handler
+	watcher   *watcher.Watcher // 可选，由main.go在启用auto_reload时通过SetWatcher注入
 	wg        sync.WaitGroup
 	ctx       context.Context
 	cancel    context.CancelFunc
 }
 
+// SetWatcher 注入规则文件监听器，使GetStats/HealthCheck能上报最近一次规则重载校验失败的错误
+func (s *Server) SetWatcher(w *watcher.Watcher) {
+	s.watcher = w
+}
+
 // NewServer 创建新的代理服务器
 func NewServer(cfg *config.Config) *Server {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -44,7 +55,9 @@ func NewServer(cfg *config.Config) *Server {
 		cancel()
 		return nil
 	}
-	
+	// 注入替换引擎，使响应体也能被流式规则替换覆盖（原来只处理请求体）
+	forwarder.SetEngine(engine)
+
 	// 创建处理器
 	handler := NewHandler(cfg, engine, forwarder)
 
@@ -62,10 +75,18 @@ func NewServer(cfg *config.Config) *Server {
 
 // Start 启动服务器
 func (s *Server) Start() error {
+	// 注册Prometheus指标
+	metrics.Init()
+
+	// 用h2c包装handler，使未加密的HTTP/2（h2c）请求（如长轮询/SSE风格的流式响应）
+	// 也能被正确识别和转发，而不会被当成HTTP/1.1处理
+	h2Server := &http2.Server{}
+	handler := h2c.NewHandler(s.handler, h2Server)
+
 	// 创建HTTP服务器
 	s.server = &http.Server{
 		Addr:         s.config.GetAddress(),
-		Handler:      s.handler,
+		Handler:      handler,
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  120 * time.Second,
@@ -163,7 +184,11 @@ func (s *Server) GetStats() map[string]interface{} {
 		"engine":    s.engine.GetStats(),
 		"forwarder": s.forwarder.GetStats(),
 	}
-	
+
+	if s.watcher != nil {
+		stats["watcher"] = s.watcher.GetStats()
+	}
+
 	return stats
 }
 
@@ -197,7 +222,18 @@ func (s *Server) HealthCheck() map[string]interface{} {
 	} else {
 		health["engine"] = "healthy"
 	}
-	
+
+	// 如果规则文件监听器存在且最近一次重载校验失败，整体状态降级，但继续使用上一份有效规则集
+	if s.watcher != nil {
+		if err := s.watcher.LastError(); err != nil {
+			health["status"] = "degraded"
+			health["rules_reload"] = map[string]interface{}{
+				"status": "failed",
+				"error":  err.Error(),
+			}
+		}
+	}
+
 	return health
 }
 
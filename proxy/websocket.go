@@ -0,0 +1,267 @@
+package proxy
+
+import (
+	"bufio"
+	"content_replace/config"
+	"content_replace/logger"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// WebSocket操作码，RFC 6455
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+// isUpgradeRequest 检查请求是否为WebSocket升级请求
+func isUpgradeRequest(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get("Connection"), "upgrade") ||
+		strings.Contains(strings.ToLower(req.Header.Get("Connection")), "upgrade")
+}
+
+func isWebSocketUpgrade(req *http.Request) bool {
+	return isUpgradeRequest(req) && strings.EqualFold(req.Header.Get("Upgrade"), "websocket")
+}
+
+// dialUpstream 根据目标URL的scheme选择明文TCP或TLS拨号上游，使wss://目标也能正确完成握手
+func dialUpstream(targetURL *url.URL) (net.Conn, error) {
+	if targetURL.Scheme == "https" || targetURL.Scheme == "wss" {
+		return tls.Dial("tcp", targetURL.Host, nil)
+	}
+	return net.Dial("tcp", targetURL.Host)
+}
+
+// handleUpgrade 劫持客户端连接，拨号上游完成WebSocket握手，随后在两个方向之间转发帧，
+// 对文本帧（opcode 0x1）应用规则引擎，二进制/控制帧原样透传
+func (h *Handler) handleUpgrade(w http.ResponseWriter, req *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "不支持连接劫持，无法处理WebSocket升级", http.StatusInternalServerError)
+		return
+	}
+
+	targetURL := h.forwarder.GetTargetURL()
+	if targetURL == nil {
+		http.Error(w, "没有可用的目标服务器", http.StatusBadGateway)
+		return
+	}
+
+	upstreamConn, err := dialUpstream(targetURL)
+	if err != nil {
+		logger.Error("拨号上游WebSocket服务器失败: %v", err)
+		http.Error(w, "连接上游服务器失败", http.StatusBadGateway)
+		return
+	}
+	defer upstreamConn.Close()
+
+	// 把原始握手请求原样转发给上游
+	if err := req.Write(upstreamConn); err != nil {
+		logger.Error("转发WebSocket握手请求失败: %v", err)
+		http.Error(w, "转发握手失败", http.StatusBadGateway)
+		return
+	}
+
+	clientConn, clientBuf, err := hijacker.Hijack()
+	if err != nil {
+		logger.Error("劫持客户端连接失败: %v", err)
+		return
+	}
+	defer clientConn.Close()
+
+	// 把上游对握手的101响应原样转发回客户端
+	upstreamReader := bufio.NewReader(upstreamConn)
+	handshakeResp, err := http.ReadResponse(upstreamReader, req)
+	if err != nil {
+		logger.Error("读取上游握手响应失败: %v", err)
+		return
+	}
+	if err := handshakeResp.Write(clientConn); err != nil {
+		logger.Error("转发握手响应给客户端失败: %v", err)
+		return
+	}
+	if handshakeResp.StatusCode != http.StatusSwitchingProtocols {
+		// 握手没有成功切换协议，不再继续帧转发
+		return
+	}
+
+	requestCtx := &config.EngineContext{
+		Method:    req.Method,
+		Path:      req.URL.Path,
+		Host:      req.Host,
+		Header:    map[string][]string(req.Header),
+		Direction: config.DirectionRequest,
+	}
+	responseCtx := &config.EngineContext{
+		Method:    req.Method,
+		Path:      req.URL.Path,
+		Host:      req.Host,
+		Header:    map[string][]string(req.Header),
+		Direction: config.DirectionResponse,
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		// 客户端->上游方向，RFC 6455 §5.3要求所有客户端发往服务端的帧都必须掩码
+		h.proxyWebSocketFrames(clientBuf.Reader, upstreamConn, requestCtx, true)
+		done <- struct{}{}
+	}()
+	go func() {
+		// 上游->客户端方向不要求掩码
+		h.proxyWebSocketFrames(upstreamReader, clientConn, responseCtx, false)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// proxyWebSocketFrames 从src读取WebSocket帧并写入dst，文本帧内容经过规则引擎重写；
+// maskOutgoing为true时按RFC 6455 §5.3对写出的帧加掩码（客户端->上游方向），否则原样不加掩码输出
+func (h *Handler) proxyWebSocketFrames(src *bufio.Reader, dst io.Writer, ctx *config.EngineContext, maskOutgoing bool) {
+	for {
+		frame, err := readWSFrame(src)
+		if err != nil {
+			if err != io.EOF {
+				logger.Debugf("读取WebSocket帧结束: %v", err)
+			}
+			return
+		}
+
+		if frame.opcode == wsOpText && len(frame.payload) > 0 {
+			rewritten, err := h.engine.Process(string(frame.payload), ctx)
+			if err != nil {
+				logger.Error("WebSocket文本帧替换失败: %v", err)
+			} else {
+				frame.payload = []byte(rewritten)
+			}
+		}
+
+		if _, err := dst.Write(frame.encode(maskOutgoing)); err != nil {
+			logger.Debugf("转发WebSocket帧失败: %v", err)
+			return
+		}
+
+		if frame.opcode == wsOpClose {
+			return
+		}
+	}
+}
+
+// wsFrame 一个解析后的WebSocket帧
+type wsFrame struct {
+	fin     bool
+	opcode  byte
+	masked  bool
+	maskKey [4]byte
+	payload []byte
+}
+
+// readWSFrame 按RFC 6455解析单个WebSocket帧
+func readWSFrame(r *bufio.Reader) (*wsFrame, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	frame := &wsFrame{
+		fin:    header[0]&0x80 != 0,
+		opcode: header[0] & 0x0F,
+		masked: header[1]&0x80 != 0,
+	}
+
+	payloadLen := int64(header[1] & 0x7F)
+	switch payloadLen {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(r, ext); err != nil {
+			return nil, err
+		}
+		payloadLen = int64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(r, ext); err != nil {
+			return nil, err
+		}
+		payloadLen = int64(binary.BigEndian.Uint64(ext))
+	}
+
+	if frame.masked {
+		if _, err := io.ReadFull(r, frame.maskKey[:]); err != nil {
+			return nil, err
+		}
+	}
+
+	payload := make([]byte, payloadLen)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	if frame.masked {
+		for i := range payload {
+			payload[i] ^= frame.maskKey[i%4]
+		}
+	}
+	frame.payload = payload
+
+	return frame, nil
+}
+
+// encode 把帧重新序列化为字节流。重写文本帧后载荷长度可能变化，需要重新计算长度字段；
+// mask为true时按RFC 6455 §5.3生成随机掩码key、置位掩码标志并对载荷异或掩码（客户端->服务端
+// 方向必须如此，否则遵循规范的上游会直接断开连接），为false时按未掩码帧输出（服务端->客户端方向）
+func (f *wsFrame) encode(mask bool) []byte {
+	var buf []byte
+
+	firstByte := f.opcode
+	if f.fin {
+		firstByte |= 0x80
+	}
+	buf = append(buf, firstByte)
+
+	payload := f.payload
+	var maskKey [4]byte
+	if mask {
+		if _, err := rand.Read(maskKey[:]); err != nil {
+			logger.Error("生成WebSocket掩码key失败: %v", err)
+		}
+		masked := make([]byte, len(payload))
+		for i, b := range payload {
+			masked[i] = b ^ maskKey[i%4]
+		}
+		payload = masked
+	}
+
+	length := len(payload)
+	lengthByte := byte(0)
+	if mask {
+		lengthByte = 0x80
+	}
+	switch {
+	case length <= 125:
+		buf = append(buf, lengthByte|byte(length))
+	case length <= 65535:
+		ext := make([]byte, 2)
+		binary.BigEndian.PutUint16(ext, uint16(length))
+		buf = append(buf, lengthByte|126)
+		buf = append(buf, ext...)
+	default:
+		ext := make([]byte, 8)
+		binary.BigEndian.PutUint64(ext, uint64(length))
+		buf = append(buf, lengthByte|127)
+		buf = append(buf, ext...)
+	}
+
+	if mask {
+		buf = append(buf, maskKey[:]...)
+	}
+	buf = append(buf, payload...)
+	return buf
+}
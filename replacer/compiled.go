@@ -0,0 +1,188 @@
+package replacer
+
+import (
+	"content_replace/config"
+	"regexp"
+	"strings"
+)
+
+// acNode Aho-Corasick自动机节点
+type acNode struct {
+	children map[byte]*acNode
+	fail     *acNode
+	// ruleIdx 记录以该节点结尾的字面量规则在 literalRules 中的下标
+	ruleIdx []int
+}
+
+// newACNode 创建自动机节点
+func newACNode() *acNode {
+	return &acNode{children: make(map[byte]*acNode)}
+}
+
+// CompiledRules 预编译后的规则集合，在 UpdateRules 时构建一次，
+// 避免每次 Process 都对每条规则重复做 Contains/正则匹配。
+type CompiledRules struct {
+	// literalRules 是 contains/prefix/suffix 模式的规则，按原始顺序保存
+	literalRules []config.Rule
+	acRoot       *acNode
+
+	// regexRules 是 regex 模式的规则
+	regexRules []compiledRegexRule
+
+	// combinedRegex 是所有正则规则 pattern 的 "(?:p1)|(?:p2)|..." 合并结果，
+	// 用来做一次快速的候选命中扫描
+	combinedRegex *regexp.Regexp
+
+	// maxPatternLen 最长字面量模式长度，供 StreamEngine 确定滑动窗口大小
+	maxPatternLen int
+}
+
+type compiledRegexRule struct {
+	rule config.Rule
+	re   *regexp.Regexp
+}
+
+// CompileRules 构建 Aho-Corasick 自动机和合并正则，供 Engine 在 UpdateRules 时调用
+func CompileRules(rules []config.Rule) *CompiledRules {
+	cr := &CompiledRules{acRoot: newACNode()}
+
+	var regexPatterns []string
+	for i, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		switch rule.Mode {
+		case config.ModeContains, config.ModePrefix, config.ModeSuffix:
+			idx := len(cr.literalRules)
+			cr.literalRules = append(cr.literalRules, rule)
+			cr.insertPattern(rule.Pattern, idx)
+			if len(rule.Pattern) > cr.maxPatternLen {
+				cr.maxPatternLen = len(rule.Pattern)
+			}
+		case config.ModeRegex:
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				// 无效正则在加载阶段应该已经被拒绝，这里兜底跳过
+				continue
+			}
+			cr.regexRules = append(cr.regexRules, compiledRegexRule{rule: rule, re: re})
+			regexPatterns = append(regexPatterns, "(?:"+rule.Pattern+")")
+			// 正则能匹配的实际文本长度没法从Pattern本身精确推出（分组/量词等），
+			// 这里用Pattern源串长度做一个保守的下限估计，供StreamEngine确定滑动窗口大小，
+			// 至少保证模式源串本身那么长的匹配不会被carry边界切断
+			if len(rule.Pattern) > cr.maxPatternLen {
+				cr.maxPatternLen = len(rule.Pattern)
+			}
+		default:
+			_ = i
+		}
+	}
+
+	cr.buildFailureLinks()
+
+	if len(regexPatterns) > 0 {
+		if combined, err := regexp.Compile(strings.Join(regexPatterns, "|")); err == nil {
+			cr.combinedRegex = combined
+		}
+	}
+
+	return cr
+}
+
+// insertPattern 将字面量模式插入自动机
+func (cr *CompiledRules) insertPattern(pattern string, ruleIdx int) {
+	if pattern == "" {
+		return
+	}
+	node := cr.acRoot
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		child, ok := node.children[c]
+		if !ok {
+			child = newACNode()
+			node.children[c] = child
+		}
+		node = child
+	}
+	node.ruleIdx = append(node.ruleIdx, ruleIdx)
+}
+
+// buildFailureLinks 使用BFS构建失败指针，使自动机可以在O(n)内完成多模式扫描
+func (cr *CompiledRules) buildFailureLinks() {
+	queue := make([]*acNode, 0)
+	for _, child := range cr.acRoot.children {
+		child.fail = cr.acRoot
+		queue = append(queue, child)
+	}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		for c, child := range node.children {
+			fail := node.fail
+			for fail != nil {
+				if next, ok := fail.children[c]; ok {
+					child.fail = next
+					break
+				}
+				fail = fail.fail
+			}
+			if child.fail == nil {
+				child.fail = cr.acRoot
+			}
+			child.ruleIdx = append(child.ruleIdx, child.fail.ruleIdx...)
+			queue = append(queue, child)
+		}
+	}
+}
+
+// HasLiteralCandidate 对内容做一次线性扫描，返回可能命中的字面量规则下标集合。
+// prefix/suffix 规则还需要 Rule.Match 做锚点位置校验，这里只负责快速筛掉肯定不命中的规则。
+func (cr *CompiledRules) HasLiteralCandidate(content string) map[int]bool {
+	hits := make(map[int]bool)
+	if cr.acRoot == nil {
+		return hits
+	}
+
+	node := cr.acRoot
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		for node != cr.acRoot {
+			if _, ok := node.children[c]; ok {
+				break
+			}
+			node = node.fail
+		}
+		if next, ok := node.children[c]; ok {
+			node = next
+		}
+		for _, idx := range node.ruleIdx {
+			hits[idx] = true
+		}
+	}
+	return hits
+}
+
+// RegexCandidateHit 用合并正则快速判断是否有任何正则规则可能命中，避免逐条跑 regexp.MatchString
+func (cr *CompiledRules) RegexCandidateHit(content string) bool {
+	if cr.combinedRegex == nil {
+		return false
+	}
+	return cr.combinedRegex.MatchString(content)
+}
+
+// MaxPatternLen 返回最长字面量模式的长度
+func (cr *CompiledRules) MaxPatternLen() int {
+	return cr.maxPatternLen
+}
+
+// LiteralRules 返回参与编译的字面量规则
+func (cr *CompiledRules) LiteralRules() []config.Rule {
+	return cr.literalRules
+}
+
+// RegexRules 返回参与编译的正则规则
+func (cr *CompiledRules) RegexRules() []compiledRegexRule {
+	return cr.regexRules
+}
@@ -0,0 +1,68 @@
+package replacer
+
+import (
+	"content_replace/config"
+	"content_replace/logger"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// TestMain在跑基准前初始化日志系统（Engine.UpdateRules等路径依赖logger.Init已执行），
+// Level设为error避免基准输出被Info/Debug日志淹没
+func TestMain(m *testing.M) {
+	if err := logger.Init(config.LoggingConfig{Level: "error", Format: "text"}, false); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// benchRules 构造一批字面量/正则混合规则，用于衡量预编译后的Aho-Corasick扫描
+// 相对逐条Contains/正则匹配的开销，替代了已废弃的config.CompiledRuleSet基准
+func benchRules(n int) []config.Rule {
+	rules := make([]config.Rule, 0, n)
+	for i := 0; i < n; i++ {
+		rules = append(rules, config.Rule{
+			Name:    fmt.Sprintf("rule-%d", i),
+			Enabled: true,
+			Mode:    config.ModeContains,
+			Pattern: fmt.Sprintf("needle-%d", i),
+			Action:  config.ActionReplace,
+			Value:   "replaced",
+		})
+	}
+	return rules
+}
+
+func BenchmarkCompileRules(b *testing.B) {
+	rules := benchRules(200)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CompileRules(rules)
+	}
+}
+
+func BenchmarkHasLiteralCandidate(b *testing.B) {
+	rules := benchRules(200)
+	compiled := CompileRules(rules)
+	content := "一段不包含任何needle的普通内容，重复若干次以模拟真实body大小。" +
+		"needle-50出现在这里一次，其余都是干扰内容。"
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		compiled.HasLiteralCandidate(content)
+	}
+}
+
+func BenchmarkEngineProcess(b *testing.B) {
+	engine := NewEngineWithRules(benchRules(200))
+	content := "一段不包含任何needle的普通内容，重复若干次以模拟真实body大小。" +
+		"needle-50出现在这里一次，其余都是干扰内容。"
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := engine.Process(content, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package replacer
 import (
 	"content_replace/config"
 	"content_replace/logger"
+	"content_replace/metrics"
 	"context"
 	"fmt"
 	"sync"
@@ -11,6 +12,7 @@ import (
 // Engine 内容替换引擎
 type Engine struct {
 	rules      []config.Rule
+	compiled   *CompiledRules // 预编译的Aho-Corasick自动机+合并正则，随UpdateRules重建
 	rulesPath  string
 	rulesPaths []string // 支持多个规则文件路径
 	mutex      sync.RWMutex
@@ -42,6 +44,19 @@ func NewEngineFromPaths(rulesPaths []string) *Engine {
 	return engine
 }
 
+// NewEngineWithRules 直接用一组已经校验过的规则构造替换引擎，不从文件加载，
+// 也不关联任何rulesPath（因此ReloadRules对它是空操作）。供admin接口的dry-run等
+// 场景临时拼装一个隔离的引擎实例，不影响线上引擎状态
+func NewEngineWithRules(rules []config.Rule) *Engine {
+	ctx, cancel := context.WithCancel(context.Background())
+	engine := &Engine{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	engine.UpdateRules(rules)
+	return engine
+}
+
 // LoadRules 加载规则文件
 func (e *Engine) LoadRules() error {
 	var rules []config.Rule
@@ -75,51 +90,150 @@ func (e *Engine) ReloadRules() error {
 	return e.LoadRules()
 }
 
-// UpdateRules 更新规则
+// UpdateRules 更新规则，并一次性重建Aho-Corasick自动机和合并正则，
+// 避免Process在每次请求时都对每条规则重新做Contains/正则匹配
 func (e *Engine) UpdateRules(rules []config.Rule) {
+	compiled := CompileRules(rules)
+
 	e.mutex.Lock()
 	e.rules = rules
+	e.compiled = compiled
 	e.mutex.Unlock()
 	logger.Info("替换引擎已更新 %d 条规则", len(rules))
 }
 
-// Process 处理内容替换
-func (e *Engine) Process(content string) (string, error) {
+// Process 处理内容替换。ctx为nil时表示不做作用范围过滤，对所有规则生效（兼容旧调用方式）
+func (e *Engine) Process(content string, ctx *config.EngineContext) (string, error) {
 	e.mutex.RLock()
 	rules := make([]config.Rule, len(e.rules))
 	copy(rules, e.rules)
+	compiled := e.compiled
 	e.mutex.RUnlock()
 
+	var requestID string
+	if ctx != nil {
+		requestID = ctx.RequestID
+	}
+	log := logger.WithID(requestID)
+
 	if len(rules) == 0 {
-		logger.Debug("没有可用的替换规则")
+		log.Debugf("没有可用的替换规则")
 		return content, nil
 	}
 
 	originalContent := content
 	modifiedContent := content
 
-	logger.Debugf("开始处理内容替换，共 %d 条规则", len(rules))
+	log.Debugf("开始处理内容替换，共 %d 条规则", len(rules))
+
+	// 先用预编译的自动机做一次O(n)候选扫描，命中的规则下标才需要真正Match/Apply，
+	// 大量规则场景下避免逐条Contains/正则扫描整个body。
+	// candidatesStale标记modifiedContent自上次扫描后是否被某条规则改写过：只要改写过，
+	// 后面规则看到的就是新内容，之前缓存的命中结果不再可信，必须在用到前重新扫描一次；
+	// 内容未被任何规则改写的常见场景下则完全复用首次扫描结果，不退化成逐条全量扫描
+	var literalHits map[int]bool
+	var regexCandidate bool
+	scanCandidates := func() {
+		if compiled != nil {
+			literalHits = compiled.HasLiteralCandidate(modifiedContent)
+			regexCandidate = compiled.RegexCandidateHit(modifiedContent)
+		}
+	}
+	scanCandidates()
+	candidatesStale := false
+	literalIdx := 0
+
+	// json_merge_patch/json_patch规则在进入主循环前统一一次性应用：它们共享同一次JSON解码/编码，
+	// 避免像其余规则一样逐条Apply各自反复Unmarshal/Marshal整个body
+	var mergeRules []config.Rule
+	for _, rule := range rules {
+		if !rule.IsEnabled() || !rule.MatchScope(ctx) {
+			continue
+		}
+		if rule.Action == config.ActionJsonMerge || rule.Action == config.ActionJsonStrategicPatch {
+			mergeRules = append(mergeRules, rule)
+		}
+	}
+	if len(mergeRules) > 0 {
+		if merged, err := config.ApplyJSONMergeRules(modifiedContent, mergeRules); err != nil {
+			log.Debugf("JSON合并类规则应用失败，跳过本次合并: %v", err)
+		} else {
+			modifiedContent = merged
+		}
+	}
 
 	for _, rule := range rules {
 		if !rule.IsEnabled() {
-			logger.Debugf("规则 %s 已禁用，跳过", rule.Name)
+			log.Debugf("规则 %s 已禁用，跳过", rule.Name)
+			continue
+		}
+
+		if rule.Action == config.ActionJsonMerge || rule.Action == config.ActionJsonStrategicPatch {
+			// 已在上面统一应用过，这里跳过，避免重复打补丁
+			continue
+		}
+
+		// 前面的规则改写过内容，之前缓存的AC/正则候选命中结果已经对不上当前modifiedContent了，
+		// 重新扫一次；scanCandidates不重置literalIdx——它只是按下标重新回答"这个下标对应的规则
+		// 在当前内容里还命不命中"，下标本身的含义（对应CompileRules里第几条字面量规则）不变
+		if candidatesStale {
+			scanCandidates()
+			candidatesStale = false
+		}
+
+		// literalIdx必须按CompileRules中"所有已启用字面量模式规则"的顺序递增（含delete_json_field，
+		// CompileRules对它同样按Mode而非Action归类），否则后续字面量规则会错位对应到前面规则的AC命中结果
+		var literalHit bool
+		isLiteralMode := rule.Mode == config.ModeContains || rule.Mode == config.ModePrefix || rule.Mode == config.ModeSuffix
+		if compiled != nil && isLiteralMode {
+			literalHit = literalHits[literalIdx]
+			literalIdx++
+		}
+
+		if !rule.MatchScope(ctx) {
+			log.Debugf("规则 %s 作用范围不匹配当前请求/响应，跳过", rule.Name)
 			continue
 		}
 
-		// 检查是否匹配
-		matched := rule.Match(modifiedContent)
+		if compiled != nil && rule.Action != config.ActionDeleteJsonField {
+			switch rule.Mode {
+			case config.ModeContains, config.ModePrefix, config.ModeSuffix:
+				if !literalHit {
+					logger.LogRuleMatch(rule.Name, string(rule.Mode), rule.Pattern, string(rule.Action), rule.Value, false)
+					continue
+				}
+			case config.ModeRegex:
+				if !regexCandidate {
+					logger.LogRuleMatch(rule.Name, string(rule.Mode), rule.Pattern, string(rule.Action), rule.Value, false)
+					continue
+				}
+			}
+		}
+
+		// 检查是否匹配。Path非空时实际的匹配判断下放到Apply内部按JSON子树逐个做，
+		// 这里的整体Match只作为日志展示，不作为是否调用Apply的依据
+		var matched bool
+		if rule.Path != "" {
+			matched = true
+		} else {
+			matched = rule.Match(ctx, modifiedContent)
+		}
 		logger.LogRuleMatch(rule.Name, string(rule.Mode), rule.Pattern, string(rule.Action), rule.Value, matched)
 
 		if matched {
+			metrics.RuleMatchTotal.WithLabelValues(rule.Name).Inc()
+
 			// 应用规则
 			beforeApply := modifiedContent
-			modifiedContent = rule.Apply(modifiedContent)
+			modifiedContent = rule.Apply(ctx, modifiedContent)
 
 			logger.LogRuleApplied(rule.Name, beforeApply, modifiedContent)
 
 			// 如果内容有变化，记录日志
 			if beforeApply != modifiedContent {
-				logger.Debugf("规则 %s 应用成功，内容已修改", rule.Name)
+				log.Debugf("规则 %s 应用成功，内容已修改", rule.Name)
+				metrics.RuleApplyTotal.WithLabelValues(rule.Name, string(rule.Mode)).Inc()
+				candidatesStale = true
 			}
 		}
 
@@ -133,10 +247,10 @@ func (e *Engine) Process(content string) (string, error) {
 
 	// 如果内容有变化，记录最终结果
 	if originalContent != modifiedContent {
-		logger.Debugf("内容替换完成，原始内容长度: %d，修改后长度: %d",
+		log.Debugf("内容替换完成，原始内容长度: %d，修改后长度: %d",
 			len(originalContent), len(modifiedContent))
 	} else {
-		logger.Debugf("内容替换完成，内容未发生改变")
+		log.Debugf("内容替换完成，内容未发生改变")
 	}
 
 	return modifiedContent, nil
@@ -187,6 +301,9 @@ func (e *Engine) EnableRule(name string) error {
 	for i := range e.rules {
 		if e.rules[i].Name == name {
 			e.rules[i].SetEnabled(true)
+			// Enabled变化会改变CompileRules的输入集合，必须重新编译e.compiled，
+			// 否则Aho-Corasick自动机仍是启用前的状态，新启用的规则永远不会命中
+			e.compiled = CompileRules(e.rules)
 			logger.Info("规则 %s 已启用", name)
 			return nil
 		}
@@ -202,6 +319,8 @@ func (e *Engine) DisableRule(name string) error {
 	for i := range e.rules {
 		if e.rules[i].Name == name {
 			e.rules[i].SetEnabled(false)
+			// 同EnableRule，禁用后也要重新编译，否则自动机仍会继续命中已禁用的规则
+			e.compiled = CompileRules(e.rules)
 			logger.Info("规则 %s 已禁用", name)
 			return nil
 		}
@@ -0,0 +1,135 @@
+package replacer
+
+import (
+	"bufio"
+	"content_replace/config"
+	"content_replace/logger"
+	"io"
+	"sync"
+)
+
+// 默认滑动窗口大小下限，即使规则集为空或模式很短也保留一个合理的缓冲区
+const minStreamWindow = 1024
+
+// StreamEngine 流式内容替换引擎，基于Engine的规则在io.Reader/io.Writer上做滑动窗口替换，
+// 使大body（大文件上传/下载）不需要一次性读入内存就能完成规则替换
+type StreamEngine struct {
+	engine       *Engine
+	windowOverride int // 非0时跳过自适应窗口计算，直接使用该值；由config.TargetConfig.StreamWindowSize注入
+
+	warnUnsupportedOnce sync.Once // 保证prefix/suffix限制的警告每个StreamEngine实例只记录一次，避免刷屏
+}
+
+// NewStreamEngine 基于已有的Engine创建流式引擎
+func NewStreamEngine(engine *Engine) *StreamEngine {
+	return &StreamEngine{engine: engine}
+}
+
+// SetWindowSize 覆盖自适应滑动窗口大小，供运维在规则模式很短但对端写入粒度很小、
+// 或者相反需要更大窗口安全跨越匹配边界时手动调大
+func (se *StreamEngine) SetWindowSize(size int) {
+	se.windowOverride = size
+}
+
+// warnUnsupportedModes 检查引擎当前是否启用了prefix/suffix规则，若有则记一次警告——
+// 这两种模式在流式转发下按窗口而非整个body的首尾判断，语义不等价于非流式路径
+func (se *StreamEngine) warnUnsupportedModes() {
+	se.engine.mutex.RLock()
+	rules := se.engine.rules
+	se.engine.mutex.RUnlock()
+
+	for _, rule := range rules {
+		if rule.IsEnabled() && (rule.Mode == config.ModePrefix || rule.Mode == config.ModeSuffix) {
+			se.warnUnsupportedOnce.Do(func() {
+				logger.Warn("规则 %s 使用了prefix/suffix模式，流式转发下这两种模式按滑动窗口而非整个body的首尾判断，可能在每个窗口边界重复/额外命中，建议避免在会走流式路径的规则集中使用", rule.Name)
+			})
+			return
+		}
+	}
+}
+
+// windowSize 滑动窗口大小，取最长规则模式的若干倍，保证跨块边界的匹配不会被错过；
+// 若通过SetWindowSize设置了覆盖值则直接使用
+func (se *StreamEngine) windowSize() int {
+	if se.windowOverride > 0 {
+		return se.windowOverride
+	}
+
+	se.engine.mutex.RLock()
+	compiled := se.engine.compiled
+	se.engine.mutex.RUnlock()
+
+	maxLen := 0
+	if compiled != nil {
+		maxLen = compiled.MaxPatternLen()
+	}
+
+	window := maxLen * 4
+	if window < minStreamWindow {
+		window = minStreamWindow
+	}
+	return window
+}
+
+// Stream 从r读取内容，按规则替换后写入w。
+// 采用"读入窗口 -> Process -> 刷出安全前缀 -> 保留可能被下一块影响的尾部"的滑动方式，
+// 使匹配可以跨越两次Read的边界，同时避免把整个body缓冲在内存里。
+//
+// 已知限制：prefix/suffix规则按"整个body的开头/结尾"定义语义，但这里每个safePart都是
+// 独立喂给Engine.Process的一个窗口，Rule.Match在窗口内部做HasPrefix/HasSuffix判断——prefix
+// 规则会在每个窗口的开头都命中一次，suffix规则会在每个非最后窗口的结尾命中一次，而不只是整个
+// body真正的首尾。流式模式下prefix/suffix规则视为不支持，启用时只记一次警告，不阻断转发
+func (se *StreamEngine) Stream(r io.Reader, w io.Writer, ctx *config.EngineContext) error {
+	se.warnUnsupportedModes()
+	window := se.windowSize()
+	reader := bufio.NewReaderSize(r, window*2)
+
+	// carry 保存上一轮末尾可能与下一块拼接后才能匹配完整的内容
+	var carry []byte
+	buf := make([]byte, window)
+
+	for {
+		n, readErr := reader.Read(buf)
+		if n > 0 {
+			chunk := append(carry, buf[:n]...)
+
+			// 末尾保留window长度，避免模式被切断在块边界上；
+			// 其余的"安全前缀"已经不可能再被后续字节影响，可以直接替换输出
+			safeLen := len(chunk) - window
+			if readErr == io.EOF || safeLen < 0 {
+				safeLen = len(chunk)
+			}
+
+			safePart := string(chunk[:safeLen])
+			processed, err := se.engine.Process(safePart, ctx)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write([]byte(processed)); err != nil {
+				return err
+			}
+
+			carry = append([]byte{}, chunk[safeLen:]...)
+		}
+
+		if readErr != nil {
+			if readErr == io.EOF {
+				break
+			}
+			return readErr
+		}
+	}
+
+	if len(carry) > 0 {
+		processed, err := se.engine.Process(string(carry), ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte(processed)); err != nil {
+			return err
+		}
+	}
+
+	logger.Debug("流式内容替换完成，窗口大小: %d", window)
+	return nil
+}
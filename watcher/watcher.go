@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -14,13 +15,16 @@ import (
 
 // Watcher 文件监听器
 type Watcher struct {
-	watcher   *fsnotify.Watcher
-	rules     []config.Rule
-	rulesPath []string
-	mutex     sync.RWMutex
-	ctx       context.Context
-	cancel    context.CancelFunc
-	callback  func([]config.Rule) error
+	watcher     *fsnotify.Watcher
+	rulesPtr    atomic.Pointer[[]config.Rule] // 当前生效的规则快照，重载成功后整体替换，in-flight请求继续用替换前的切片
+	rulesPath   []string
+	mutex       sync.RWMutex
+	ctx         context.Context
+	cancel      context.CancelFunc
+	callback    func([]config.Rule) error
+	subscribers []func([]config.Rule) // 除callback外，允许多个关注方通过Subscribe在规则重载后收到新快照
+	reloadCh    chan struct{}          // 由watchLoop写入、reloadLoop消费，缓冲为1实现多次事件合并为一次重载
+	lastErr     error                  // 最近一次重载校验失败的错误，校验失败时保留上一份有效规则集不变
 }
 
 // NewWatcher 创建新的文件监听器
@@ -37,9 +41,10 @@ func NewWatcher(rulesPath []string, callback func([]config.Rule) error) (*Watche
 		ctx:      ctx,
 		cancel:   cancel,
 		callback: callback,
+		reloadCh: make(chan struct{}, 1),
 	}
 
-	// 转换并存储绝对路径
+	// 转换并存储绝对路径，同时把规则文件里通过easy.delete.contains引用的外部YAML也纳入监听范围
 	absPaths := make([]string, 0, len(rulesPath))
 	for _, path := range rulesPath {
 		abs, err := filepath.Abs(path)
@@ -49,6 +54,14 @@ func NewWatcher(rulesPath []string, callback func([]config.Rule) error) (*Watche
 		}
 		absPaths = append(absPaths, abs)
 	}
+	for _, path := range config.ReferencedExternalPaths(rulesPath) {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			logger.Error("获取外部引用文件绝对路径失败 %s: %v", path, err)
+			continue
+		}
+		absPaths = append(absPaths, abs)
+	}
 	w.rulesPath = absPaths
 
 	// 初始加载规则
@@ -73,11 +86,20 @@ func NewWatcher(rulesPath []string, callback func([]config.Rule) error) (*Watche
 	return w, nil
 }
 
+// Subscribe 注册一个回调，在每次规则重载成功后以最新规则快照调用，
+// 供代理引擎之外的其他关注方（如未来的管理面板/指标）在不侵入callback的情况下感知规则变化
+func (w *Watcher) Subscribe(fn func([]config.Rule)) {
+	w.mutex.Lock()
+	w.subscribers = append(w.subscribers, fn)
+	w.mutex.Unlock()
+}
+
 // Start 开始监听文件变化
 func (w *Watcher) Start() {
 	logger.Info("开始监听规则文件变化")
 
 	go w.watchLoop()
+	go w.reloadLoop()
 }
 
 // Stop 停止监听
@@ -87,13 +109,9 @@ func (w *Watcher) Stop() {
 	w.watcher.Close()
 }
 
-// watchLoop 监听循环
+// watchLoop 监听fsnotify事件，只负责识别相关文件事件并请求一次重载，
+// 真正的防抖和重载执行全部交给reloadLoop这唯一一个goroutine完成
 func (w *Watcher) watchLoop() {
-	debounceTimer := time.NewTimer(0)
-	if !debounceTimer.Stop() {
-		<-debounceTimer.C // 立即停止定时器
-	}
-
 	for {
 		select {
 		case event, ok := <-w.watcher.Events:
@@ -101,7 +119,7 @@ func (w *Watcher) watchLoop() {
 				return
 			}
 
-			w.handleEvent(event, debounceTimer)
+			w.handleEvent(event)
 
 		case err, ok := <-w.watcher.Errors:
 			if !ok {
@@ -116,7 +134,7 @@ func (w *Watcher) watchLoop() {
 }
 
 // handleEvent 处理文件事件
-func (w *Watcher) handleEvent(event fsnotify.Event, debounceTimer *time.Timer) {
+func (w *Watcher) handleEvent(event fsnotify.Event) {
 	// 检查是否是我们监听的规则文件
 	if !w.isWatchedFile(event.Name) {
 		return
@@ -126,14 +144,48 @@ func (w *Watcher) handleEvent(event fsnotify.Event, debounceTimer *time.Timer) {
 
 	// 只处理写入和创建事件
 	if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
-		// 重置防抖定时器
-		debounceTimer.Stop()
-		debounceTimer.Reset(500 * time.Millisecond) // 500ms防抖
+		w.requestReload()
+	}
+}
+
+// requestReload 请求一次重载，非阻塞：如果已有一次待处理的请求，直接丢弃本次信号，
+// 由reloadLoop里的防抖定时器统一合并短时间内连续发生的多次文件事件
+func (w *Watcher) requestReload() {
+	select {
+	case w.reloadCh <- struct{}{}:
+	default:
+	}
+}
+
+// reloadLoop 是唯一拥有防抖定时器的goroutine，串行执行重载，
+// 避免原来每个事件各自起一个goroutine等待定时器、导致reloadRules被并发调用的问题
+func (w *Watcher) reloadLoop() {
+	var timer *time.Timer
+	var timerC <-chan time.Time
 
-		go func() {
-			<-debounceTimer.C
+	for {
+		select {
+		case <-w.reloadCh:
+			if timer == nil {
+				timer = time.NewTimer(500 * time.Millisecond)
+			} else {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(500 * time.Millisecond)
+			}
+			timerC = timer.C
+
+		case <-timerC:
+			timerC = nil
 			w.reloadRules()
-		}()
+
+		case <-w.ctx.Done():
+			return
+		}
 	}
 }
 
@@ -158,59 +210,91 @@ func (w *Watcher) reloadRules() {
 	logger.Info("检测到规则文件变化，重新加载规则...")
 
 	if err := w.loadRules(); err != nil {
-		logger.Error("重新加载规则失败: %v", err)
+		logger.Error("重新加载规则失败，保留上一份有效规则集不变: %v", err)
 		return
 	}
 
+	rules := w.GetRules()
+
 	// 调用回调函数
 	if w.callback != nil {
-		if err := w.callback(w.getRules()); err != nil {
+		if err := w.callback(rules); err != nil {
 			logger.Error("规则重载回调失败: %v", err)
 		} else {
 			logger.Info("规则重载成功")
 		}
 	}
+
+	w.mutex.RLock()
+	subscribers := make([]func([]config.Rule), len(w.subscribers))
+	copy(subscribers, w.subscribers)
+	w.mutex.RUnlock()
+	for _, fn := range subscribers {
+		fn(rules)
+	}
 }
 
-// loadRules 加载规则
+// loadRules 加载并校验全部规则文件到一个临时切片，只有全部通过validateRules
+// （正则编译、重名检测、捕获组引用检查）后才原子替换rulesPtr指向的生效规则集；
+// 校验失败时rulesPtr保持不变，错误记录到lastErr供GetStats上报。in-flight的请求
+// 继续持有替换前取到的切片，不受并发重载影响
 func (w *Watcher) loadRules() error {
 	// 注意：这里我们使用绝对路径来加载规则
-	allRules, err := config.LoadRulesFromPaths(w.rulesPath)
+	stagedRules, err := config.LoadRulesFromPaths(w.rulesPath)
 	if err != nil {
+		w.mutex.Lock()
+		w.lastErr = err
+		w.mutex.Unlock()
 		return fmt.Errorf("加载规则失败: %v", err)
 	}
 
+	w.rulesPtr.Store(&stagedRules)
+
 	w.mutex.Lock()
-	w.rules = allRules
+	w.lastErr = nil
 	w.mutex.Unlock()
 
-	logger.Info("成功加载 %d 条规则", len(allRules))
+	logger.Info("成功加载 %d 条规则", len(stagedRules))
 	return nil
 }
 
-// GetRules 获取当前规则
+// GetRules 获取当前规则快照
 func (w *Watcher) GetRules() []config.Rule {
-	w.mutex.RLock()
-	defer w.mutex.RUnlock()
+	rules := w.rulesPtr.Load()
+	if rules == nil {
+		return nil
+	}
 
-	rules := make([]config.Rule, len(w.rules))
-	copy(rules, w.rules)
-	return rules
+	result := make([]config.Rule, len(*rules))
+	copy(result, *rules)
+	return result
 }
 
-// getRules 内部获取规则（不加锁）
-func (w *Watcher) getRules() []config.Rule {
-	return w.rules
+// LastError 返回最近一次重载校验失败的错误，从未失败或尚未发生过重载时为nil
+func (w *Watcher) LastError() error {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.lastErr
 }
 
 // GetStats 获取监听器统计信息
 func (w *Watcher) GetStats() map[string]interface{} {
+	rules := w.rulesPtr.Load()
+	rulesCount := 0
+	if rules != nil {
+		rulesCount = len(*rules)
+	}
+
 	w.mutex.RLock()
 	defer w.mutex.RUnlock()
 
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"watching_files": w.rulesPath,
-		"rules_count":    len(w.rules),
+		"rules_count":    rulesCount,
 		"watcher_active": true,
 	}
-}
\ No newline at end of file
+	if w.lastErr != nil {
+		stats["last_reload_error"] = w.lastErr.Error()
+	}
+	return stats
+}